@@ -3,12 +3,15 @@ package ast
 import (
 	"bytes"
 	"donkey/token"
+	"strconv"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Span is the source range from the first to the last token of the node
+	Span() token.TokenLocation
 }
 
 type Statement interface {
@@ -35,6 +38,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Span() token.TokenLocation {
+	if len(p.Statements) == 0 {
+		return token.TokenLocation{}
+	}
+	return p.Statements[0].Span().Through(p.Statements[len(p.Statements)-1].Span())
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -56,6 +66,7 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Span() token.TokenLocation { return i.Token.Location }
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -63,9 +74,10 @@ func (i *Identifier) String() string {
 // LetStatement
 // ------------
 type LetStatement struct {
-	Token token.Token // token.LET
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // token.LET
+	Name     *Identifier
+	Value    Expression
+	Exported bool // `export let`, makes the binding reachable from importing modules
 }
 
 func (ls *LetStatement) statementNode() {}
@@ -73,9 +85,16 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Span() token.TokenLocation {
+	return ls.Token.Location.Through(spanOf(ls.Value))
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
+	if ls.Exported {
+		out.WriteString("export ")
+	}
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.Value)
 	out.WriteString(" = ")
@@ -101,6 +120,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Span() token.TokenLocation {
+	return rs.Token.Location.Through(spanOf(rs.ReturnValue))
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -124,6 +147,13 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Span() token.TokenLocation {
+	if es.Expression == nil {
+		return es.Token.Location
+	}
+	return es.Expression.Span()
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -131,10 +161,43 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// BreakStatement
+// ----------------
+type BreakStatement struct {
+	Token token.Token // token.BREAK
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Span() token.TokenLocation { return bs.Token.Location }
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// ContinueStatement
+// ----------------
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Span() token.TokenLocation { return cs.Token.Location }
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
 // BlockStatement
 // ----------------
 type BlockStatement struct {
 	Token      token.Token // the '{' token
+	RBrace     token.Token // the '}' token
 	Statements []Statement
 	Async      bool // if the block is colored as async
 }
@@ -144,6 +207,10 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Span() token.TokenLocation {
+	return bs.Token.Location.Through(bs.RBrace.Location)
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -165,6 +232,7 @@ func (bl *BooleanLiteral) expressionNode() {}
 func (bl *BooleanLiteral) TokenLiteral() string {
 	return bl.Token.Literal
 }
+func (bl *BooleanLiteral) Span() token.TokenLocation { return bl.Token.Location }
 func (bl *BooleanLiteral) String() string {
 	return bl.Token.Literal
 }
@@ -180,10 +248,27 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Span() token.TokenLocation { return il.Token.Location }
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral
+// -------------
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) Span() token.TokenLocation { return fl.Token.Location }
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // StringLiteral
 // -------------
 type StringLiteral struct {
@@ -195,14 +280,52 @@ func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
+func (sl *StringLiteral) Span() token.TokenLocation { return sl.Token.Location }
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// InterpolatedString
+// -------------
+// InterpolatedString is a string with embedded expressions like "total: ${a + b}".
+// Texts surround the expressions, so there is always one more text than expressions.
+type InterpolatedString struct {
+	Token       token.Token // the TEMPLATE_START token
+	End         token.Token // the TEMPLATE_END token
+	Texts       []string
+	Expressions []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+func (is *InterpolatedString) Span() token.TokenLocation {
+	return is.Token.Location.Through(is.End.Location)
+}
+
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for i, text := range is.Texts {
+		out.WriteString(text)
+		if i < len(is.Expressions) {
+			out.WriteString("${")
+			out.WriteString(is.Expressions[i].String())
+			out.WriteString("}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 // ArrayLiteral
 // -------------
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
+	RBracket token.Token // the ']' token
 	Elements []Expression
 }
 
@@ -210,6 +333,10 @@ func (al *ArrayLiteral) expressionNode() {}
 func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
+func (al *ArrayLiteral) Span() token.TokenLocation {
+	return al.Token.Location.Through(al.RBracket.Location)
+}
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -237,6 +364,10 @@ func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Span() token.TokenLocation {
+	return fl.Token.Location.Through(fl.Body.Span())
+}
+
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -264,6 +395,10 @@ type MacroLiteral struct {
 
 func (ml *MacroLiteral) expressionNode()      {}
 func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Span() token.TokenLocation {
+	return ml.Token.Location.Through(ml.Body.Span())
+}
+
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
 
@@ -284,14 +419,19 @@ func (ml *MacroLiteral) String() string {
 // HashLiteral
 // -------------
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	RBrace token.Token // the '}' token
+	Pairs  map[Expression]Expression
 }
 
 func (hl *HashLiteral) expressionNode() {}
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
+func (hl *HashLiteral) Span() token.TokenLocation {
+	return hl.Token.Location.Through(hl.RBrace.Location)
+}
+
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -319,6 +459,10 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Span() token.TokenLocation {
+	return pe.Token.Location.Through(spanOf(pe.Right))
+}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -330,6 +474,25 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// AwaitExpression
+// ----------------
+type AwaitExpression struct {
+	Token token.Token // the await token
+	Value Expression  // usually evaluates to a future
+}
+
+func (ae *AwaitExpression) expressionNode() {}
+func (ae *AwaitExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AwaitExpression) Span() token.TokenLocation {
+	return ae.Token.Location.Through(spanOf(ae.Value))
+}
+
+func (ae *AwaitExpression) String() string {
+	return "(await " + ae.Value.String() + ")"
+}
+
 // InfixExpression
 // ----------------
 type InfixExpression struct {
@@ -343,6 +506,10 @@ func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Span() token.TokenLocation {
+	return spanOf(ie.Left).Through(spanOf(ie.Right))
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -355,28 +522,70 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression
+// ----------------
+type AssignExpression struct {
+	Token    token.Token // the assignment token e.g.: '=' or '+='
+	Operator string
+	Target   Expression // *Identifier or *IndexExpression
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Span() token.TokenLocation {
+	return spanOf(ae.Target).Through(spanOf(ae.Value))
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+
+	return out.String()
+}
+
 // IfExpression
 // ----------------
 type IfExpression struct {
-	Token       token.Token // IF token
-	Condition   Expression
-	Consequence *BlockStatement
-	Alternative *BlockStatement
+	Token         token.Token // IF token
+	Condition     Expression
+	Consequence   *BlockStatement
+	Alternative   *BlockStatement // the `else { }` block
+	AlternativeIf *IfExpression   // the `else if` branch, Alternative is nil if this is set
 }
 
 func (ife *IfExpression) expressionNode() {}
 func (ife *IfExpression) TokenLiteral() string {
 	return ife.Token.Literal
 }
+func (ife *IfExpression) Span() token.TokenLocation {
+	switch {
+	case ife.AlternativeIf != nil:
+		return ife.Token.Location.Through(ife.AlternativeIf.Span())
+	case ife.Alternative != nil:
+		return ife.Token.Location.Through(ife.Alternative.Span())
+	default:
+		return ife.Token.Location.Through(ife.Consequence.Span())
+	}
+}
+
 func (ife *IfExpression) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("if")
+	out.WriteString("if ")
 	out.WriteString(ife.Condition.String())
 	out.WriteString(" ")
 	out.WriteString(ife.Consequence.String())
 
-	if ife.Alternative != nil {
+	if ife.AlternativeIf != nil {
+		out.WriteString("else ")
+		out.WriteString(ife.AlternativeIf.String())
+	} else if ife.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(ife.Alternative.String())
 	}
@@ -384,10 +593,52 @@ func (ife *IfExpression) String() string {
 	return out.String()
 }
 
+// ForExpression
+// ----------------
+// ForExpression is either a for-in loop `for (k, v in iterable) {}` or a
+// condition-only loop `for (condition) {}`. For the latter Iterable is nil.
+type ForExpression struct {
+	Token     token.Token // FOR token
+	Key       *Identifier // optional, only set if two loop variables are given
+	Value     *Identifier
+	Iterable  Expression
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (fe *ForExpression) expressionNode() {}
+func (fe *ForExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+func (fe *ForExpression) Span() token.TokenLocation {
+	return fe.Token.Location.Through(fe.Body.Span())
+}
+
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fe.Iterable != nil {
+		if fe.Key != nil {
+			out.WriteString(fe.Key.String() + ", ")
+		}
+		out.WriteString(fe.Value.String())
+		out.WriteString(" in ")
+		out.WriteString(fe.Iterable.String())
+	} else {
+		out.WriteString(fe.Condition.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
 // CallExpression
 // ----------------
 type CallExpression struct {
 	Token     token.Token // the '(' token
+	RParen    token.Token // the ')' token
 	Function  Expression
 	Arguments []Expression
 }
@@ -396,6 +647,10 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Span() token.TokenLocation {
+	return spanOf(ce.Function).Through(ce.RParen.Location)
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -416,15 +671,20 @@ func (ce *CallExpression) String() string {
 // IndexExpression
 // ----------------
 type IndexExpression struct {
-	Token token.Token // the [ token, TODO: allow .2 index expression
-	Left  Expression
-	Index Expression
+	Token    token.Token // the [ token
+	RBracket token.Token // the ] token
+	Left     Expression
+	Index    Expression
 }
 
 func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IndexExpression) Span() token.TokenLocation {
+	return spanOf(ie.Left).Through(ie.RBracket.Location)
+}
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -436,3 +696,146 @@ func (ie *IndexExpression) String() string {
 
 	return out.String()
 }
+
+// MemberExpression
+// ----------------
+type MemberExpression struct {
+	Token    token.Token // the . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MemberExpression) Span() token.TokenLocation {
+	return spanOf(me.Object).Through(me.Property.Span())
+}
+
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Property.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// ImportExpression
+// ----------------
+// ImportExpression loads a module file `import "lib.dk" as lib`.
+// Alias is nil if the module is bound to the name of its file.
+type ImportExpression struct {
+	Token token.Token // the import token
+	Path  *StringLiteral
+	Alias *Identifier
+}
+
+func (ie *ImportExpression) expressionNode() {}
+func (ie *ImportExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *ImportExpression) Span() token.TokenLocation {
+	if ie.Alias != nil {
+		return ie.Token.Location.Through(ie.Alias.Span())
+	}
+	return ie.Token.Location.Through(ie.Path.Span())
+}
+
+func (ie *ImportExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("import ")
+	out.WriteString(strconv.Quote(ie.Path.Value))
+	if ie.Alias != nil {
+		out.WriteString(" as ")
+		out.WriteString(ie.Alias.String())
+	}
+
+	return out.String()
+}
+
+// SelectExpression
+// ----------------
+// SelectExpression waits for the first of several channel operations:
+//
+//	select { case msg = ch.recv() { ... } case out.send(1) { ... } default { ... } }
+type SelectExpression struct {
+	Token   token.Token // the select token
+	RBrace  token.Token // the closing '}' token
+	Cases   []*SelectCase
+	Default *BlockStatement // nil without a default branch
+}
+
+func (se *SelectExpression) expressionNode() {}
+func (se *SelectExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SelectExpression) Span() token.TokenLocation {
+	return se.Token.Location.Through(se.RBrace.Location)
+}
+
+func (se *SelectExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("select { ")
+	for _, c := range se.Cases {
+		out.WriteString(c.String())
+		out.WriteString(" ")
+	}
+	if se.Default != nil {
+		out.WriteString("default { ")
+		out.WriteString(se.Default.String())
+		out.WriteString(" } ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// SelectCase is a single `case` of a select. Value is nil for receiving,
+// Name is the optional binding of the received value within Body.
+type SelectCase struct {
+	Token   token.Token // the case token
+	Name    *Identifier
+	Channel Expression
+	Value   Expression
+	Body    *BlockStatement
+}
+
+func (sc *SelectCase) Span() token.TokenLocation {
+	return sc.Token.Location.Through(sc.Body.Span())
+}
+
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("case ")
+	if sc.Name != nil {
+		out.WriteString(sc.Name.String())
+		out.WriteString(" = ")
+	}
+	out.WriteString(sc.Channel.String())
+	if sc.Value == nil {
+		out.WriteString(".recv()")
+	} else {
+		out.WriteString(".send(" + sc.Value.String() + ")")
+	}
+	out.WriteString(" { ")
+	out.WriteString(sc.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// spanOf returns the span of the node, or no location if it is missing
+func spanOf(node Node) token.TokenLocation {
+	if node == nil {
+		return token.TokenLocation{}
+	}
+	return node.Span()
+}
@@ -19,19 +19,52 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 
+	case *AssignExpression:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 
+	case *AwaitExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *SelectExpression:
+		for _, c := range node.Cases {
+			c.Channel, _ = Modify(c.Channel, modifier).(Expression)
+			if c.Value != nil {
+				c.Value, _ = Modify(c.Value, modifier).(Expression)
+			}
+			c.Body, _ = Modify(c.Body, modifier).(*BlockStatement)
+		}
+		if node.Default != nil {
+			node.Default, _ = Modify(node.Default, modifier).(*BlockStatement)
+		}
+
 	case *IndexExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Index, _ = Modify(node.Index, modifier).(Expression)
 
+	case *MemberExpression:
+		node.Object, _ = Modify(node.Object, modifier).(Expression)
+
 	case *IfExpression:
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
 		if node.Alternative != nil {
 			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
 		}
+		if node.AlternativeIf != nil {
+			node.AlternativeIf, _ = Modify(node.AlternativeIf, modifier).(*IfExpression)
+		}
+
+	case *ForExpression:
+		if node.Iterable != nil {
+			node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		} else {
+			node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 
 	case *BlockStatement:
 		for i, _ := range node.Statements {
@@ -55,6 +88,11 @@ func Modify(node Node, modifier ModifierFunc) Node {
 			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
 		}
 
+	case *InterpolatedString:
+		for i, _ := range node.Expressions {
+			node.Expressions[i], _ = Modify(node.Expressions[i], modifier).(Expression)
+		}
+
 	case *HashLiteral:
 		newPairs := make(map[Expression]Expression)
 		for key, val := range node.Pairs {
@@ -51,6 +51,10 @@ func TestModify(t *testing.T) {
 			&InfixExpression{Left: two(), Operator: "+", Right: one()},
 			&InfixExpression{Left: two(), Operator: "+", Right: two()},
 		},
+		{
+			&AssignExpression{Target: &IndexExpression{Left: one(), Index: one()}, Operator: "=", Value: one()},
+			&AssignExpression{Target: &IndexExpression{Left: two(), Index: two()}, Operator: "=", Value: two()},
+		},
 		{
 			&PrefixExpression{Operator: "-", Right: one()},
 			&PrefixExpression{Operator: "-", Right: two()},
@@ -59,6 +63,28 @@ func TestModify(t *testing.T) {
 			&IndexExpression{Left: one(), Index: one()},
 			&IndexExpression{Left: two(), Index: two()},
 		},
+		{
+			&AwaitExpression{Value: one()},
+			&AwaitExpression{Value: two()},
+		},
+		{
+			&SelectExpression{
+				Cases: []*SelectCase{
+					{Channel: one(), Value: one(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+				},
+				Default: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&SelectExpression{
+				Cases: []*SelectCase{
+					{Channel: two(), Value: two(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+				},
+				Default: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&MemberExpression{Object: one(), Property: &Identifier{Value: "x"}},
+			&MemberExpression{Object: two(), Property: &Identifier{Value: "x"}},
+		},
 		{
 			&IfExpression{
 				Condition: one(),
@@ -87,6 +113,24 @@ func TestModify(t *testing.T) {
 				},
 			},
 		},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{}},
+				AlternativeIf: &IfExpression{
+					Condition:   one(),
+					Consequence: &BlockStatement{Statements: []Statement{}},
+				},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{}},
+				AlternativeIf: &IfExpression{
+					Condition:   two(),
+					Consequence: &BlockStatement{Statements: []Statement{}},
+				},
+			},
+		},
 		{
 			&ReturnStatement{ReturnValue: one()},
 			&ReturnStatement{ReturnValue: two()},
@@ -117,6 +161,40 @@ func TestModify(t *testing.T) {
 			&ArrayLiteral{Elements: []Expression{one(), one()}},
 			&ArrayLiteral{Elements: []Expression{two(), two()}},
 		},
+		{
+			&InterpolatedString{Texts: []string{"a", "b", ""}, Expressions: []Expression{one(), one()}},
+			&InterpolatedString{Texts: []string{"a", "b", ""}, Expressions: []Expression{two(), two()}},
+		},
+		{
+			&ForExpression{
+				Value:    &Identifier{Value: "x"},
+				Iterable: one(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&ForExpression{
+				Value:    &Identifier{Value: "x"},
+				Iterable: two(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ForExpression{
+				Condition: one(),
+				Body:      &BlockStatement{Statements: []Statement{}},
+			},
+			&ForExpression{
+				Condition: two(),
+				Body:      &BlockStatement{Statements: []Statement{}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
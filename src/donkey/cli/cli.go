@@ -0,0 +1,418 @@
+package cli
+
+import (
+	"donkey/ast"
+	"donkey/constants"
+	"donkey/diagnostics"
+	"donkey/evaluator"
+	"donkey/formatter"
+	"donkey/lexer"
+	"donkey/object"
+	"donkey/parser"
+	"donkey/repl"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// Exit codes of the donkey command
+const (
+	ExitSuccess      = 0
+	ExitRuntimeError = 1 // the program failed, including macro expansion and unhandled async errors
+	ExitParseError   = 2
+	ExitUsageError   = 3 // wrong arguments or unreadable files
+	ExitUnformatted  = 4 // `fmt -check` found files that are not formatted
+)
+
+const usage = `usage: donkey [flags] [command] [arguments]
+
+commands:
+  run [file.dk] [args]  run a file, without a file or with "-" the script is read from stdin.
+                        The script can read its arguments from the array "args".
+  eval 'expr'           evaluate the expression and print its result
+  check file.dk...      parse the files and expand their macros without running them
+  fmt [file.dk...]      print the formatted files, or stdin without files.
+                        -w rewrites the files, -check lists the files that are not formatted.
+  repl                  start the interactive REPL, this is the default
+
+A file given without a command is run, so scripts can start with "#!/usr/bin/env donkey".
+
+exit codes: 0 success, 1 runtime error, 2 parse error, 3 usage error, 4 unformatted files
+
+flags:
+`
+
+// command holds the streams and options shared by all subcommands
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	terminal     bool // whether stdout is a terminal, which enables colors and the greeting
+	asyncTimeout time.Duration
+	seed         int64
+	unhandled    int // errors of async functions that were never awaited
+}
+
+// Run executes the donkey command line and returns the exit code
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &command{
+		stdin:        stdin,
+		stdout:       stdout,
+		stderr:       stderr,
+		terminal:     isTerminal(stdout),
+		asyncTimeout: constants.AsyncTaskTimeout,
+	}
+
+	evaluator.SetUnhandledErrorHandler(func(err *evaluator.AsyncError) {
+		c.unhandled++
+		fmt.Fprintln(c.stderr, err.Error())
+	})
+
+	flags := c.flagSet("donkey", true)
+	if code, ok := c.parseFlags(flags, args); !ok {
+		return code
+	}
+	args = flags.Args()
+
+	if len(args) == 0 {
+		return c.repl(nil)
+	}
+
+	switch args[0] {
+	case "run":
+		return c.run(args[1:])
+	case "eval":
+		return c.eval(args[1:])
+	case "check":
+		return c.check(args[1:])
+	case "fmt":
+		return c.format(args[1:])
+	case "repl":
+		return c.repl(args[1:])
+	case "help":
+		c.printUsage(flags)
+		return ExitSuccess
+	default:
+		// `donkey file.dk`, e.g. from a shebang line
+		return c.runFile(args[0], args[1:])
+	}
+}
+
+// flagSet creates the flags of a subcommand. Runtime flags are only accepted by commands that evaluate code.
+func (c *command) flagSet(name string, runtime bool) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() { c.printUsage(flags) }
+
+	if runtime {
+		flags.DurationVar(&c.asyncTimeout, "async-timeout", c.asyncTimeout,
+			"how long to wait for running async functions before exiting")
+		flags.Int64Var(&c.seed, "seed", c.seed,
+			"run async functions one at a time, interleaved in the order given by this seed (0 runs them in parallel)")
+	}
+	return flags
+}
+
+// parseFlags reports false with the exit code if the command should not continue, e.g. after `-h`
+func (c *command) parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitSuccess, false
+	}
+	if err != nil {
+		return ExitUsageError, false
+	}
+	return ExitSuccess, true
+}
+
+func (c *command) printUsage(flags *flag.FlagSet) {
+	fmt.Fprint(c.stderr, usage)
+	flags.PrintDefaults()
+}
+
+func (c *command) usageError(format string, a ...interface{}) int {
+	fmt.Fprintf(c.stderr, format+"\n", a...)
+	fmt.Fprintln(c.stderr, `run "donkey help" for usage`)
+	return ExitUsageError
+}
+
+func (c *command) run(args []string) int {
+	flags := c.flagSet("run", true)
+	if code, ok := c.parseFlags(flags, args); !ok {
+		return code
+	}
+	args = flags.Args()
+
+	if len(args) == 0 {
+		return c.runFile("-", nil)
+	}
+	return c.runFile(args[0], args[1:])
+}
+
+// runFile runs the file, or stdin if the path is "-"
+func (c *command) runFile(path string, args []string) int {
+	var input []byte
+	var err error
+	if path == "-" {
+		input, err = io.ReadAll(c.stdin)
+		path = ""
+	} else {
+		input, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return ExitUsageError
+	}
+
+	return c.execute(string(input), path, args, false)
+}
+
+func (c *command) eval(args []string) int {
+	flags := c.flagSet("eval", true)
+	if code, ok := c.parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		return c.usageError("eval expects exactly one expression, got %d arguments", flags.NArg())
+	}
+
+	return c.execute(flags.Arg(0), "", nil, true)
+}
+
+// execute parses, expands and evaluates the source, then waits for its async functions.
+// Errors are rendered with the offending code to stderr.
+func (c *command) execute(source, path string, args []string, printResult bool) (code int) {
+	// a bug in the interpreter is a runtime error, not Go's exit status 2, which means a parse error here
+	defer func() {
+		if r := recover(); r != nil {
+			err := &object.Error{Message: fmt.Sprintf("internal error: %v", r)}
+			fmt.Fprintln(c.stderr, diagnostics.RenderRuntimeError(source, err))
+			code = ExitRuntimeError
+		}
+	}()
+
+	c.startScheduler()
+
+	program, code := c.parse(source, path)
+	if code != ExitSuccess {
+		return code
+	}
+
+	env := object.NewEnvironment()
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		env = object.NewFileEnvironment(abs)
+	}
+	env.Set("args", stringArray(args))
+
+	evaluated := evaluator.Eval(program, env)
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(c.stderr, diagnostics.RenderRuntimeError(source, err))
+		code = ExitRuntimeError
+	} else if printResult && evaluated != nil && evaluated != evaluator.NULL {
+		fmt.Fprintln(c.stdout, evaluated.Inspect())
+	}
+
+	c.waitForTasks()
+	if c.unhandled > 0 && code == ExitSuccess {
+		code = ExitRuntimeError
+	}
+	return code
+}
+
+// parse parses the source and expands its macros
+func (c *command) parse(source, path string) (*ast.Program, int) {
+	p := parser.New(lexer.New(source, lexer.WithFile(path)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		c.printParseErrors(source, p.Errors())
+		return nil, ExitParseError
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintln(c.stderr, diagnostics.RenderRuntimeError(source, err))
+		return nil, ExitRuntimeError
+	}
+	return expanded.(*ast.Program), ExitSuccess
+}
+
+func (c *command) printParseErrors(source string, errors []*parser.ParseError) {
+	fmt.Fprint(c.stderr, constants.ParserErrorPrompt)
+	for _, err := range errors {
+		fmt.Fprintln(c.stderr, diagnostics.RenderParseError(source, err))
+	}
+}
+
+// check parses all files and reports the most severe failure, parse errors before macro errors
+func (c *command) check(args []string) int {
+	flags := c.flagSet("check", false)
+	if code, ok := c.parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() == 0 {
+		return c.usageError("check expects at least one file")
+	}
+
+	result := ExitSuccess
+	for _, path := range flags.Args() {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return ExitUsageError
+		}
+
+		if _, code := c.parse(string(input), path); code == ExitParseError || result == ExitSuccess {
+			result = code
+		}
+	}
+	return result
+}
+
+// format formats the files, or stdin if there are none. Parse errors are reported before unformatted files.
+func (c *command) format(args []string) int {
+	flags := c.flagSet("fmt", false)
+	write := flags.Bool("w", false, "write the formatted source back to the files instead of printing it")
+	check := flags.Bool("check", false, "only list the files that are not formatted, exiting with 4 if there are any")
+	if code, ok := c.parseFlags(flags, args); !ok {
+		return code
+	}
+	if *write && *check {
+		return c.usageError("fmt accepts either -w or -check")
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			return c.usageError("fmt -w expects at least one file")
+		}
+		input, err := io.ReadAll(c.stdin)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return ExitUsageError
+		}
+		return c.formatSource(string(input), "", false, *check)
+	}
+
+	result := ExitSuccess
+	for _, path := range flags.Args() {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return ExitUsageError
+		}
+
+		if code := c.formatSource(string(input), path, *write, *check); code == ExitParseError || result == ExitSuccess {
+			result = code
+		}
+	}
+	return result
+}
+
+// formatSource prints the formatted source, writes it back to the file or reports whether it is formatted
+func (c *command) formatSource(source, path string, write, check bool) int {
+	formatted, errs := formatter.Format(source, lexer.WithFile(path))
+	if errs != nil {
+		c.printParseErrors(source, errs)
+		return ExitParseError
+	}
+
+	switch {
+	case check:
+		if formatted != source {
+			if path == "" {
+				path = "<stdin>"
+			}
+			fmt.Fprintln(c.stdout, path)
+			return ExitUnformatted
+		}
+	case write:
+		if formatted == source {
+			return ExitSuccess
+		}
+		info, err := os.Stat(path)
+		if err == nil {
+			err = os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return ExitUsageError
+		}
+	default:
+		io.WriteString(c.stdout, formatted)
+	}
+	return ExitSuccess
+}
+
+func (c *command) repl(args []string) int {
+	flags := c.flagSet("repl", true)
+	if code, ok := c.parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 0 {
+		return c.usageError("repl takes no arguments")
+	}
+	c.startScheduler()
+
+	var opts []repl.Option
+	if c.terminal {
+		name := "there"
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		fmt.Fprintf(c.stdout, "Hello %s! This is the %s programming language!\n", name, constants.LangName)
+		fmt.Fprintf(c.stdout, "Feel free to type in commands\n")
+		opts = append(opts, repl.WithHistory(repl.DefaultHistoryPath()))
+	} else {
+		opts = append(opts, repl.WithoutColors())
+	}
+
+	repl.Start(c.stdin, c.stdout, opts...)
+	c.waitForTasks()
+
+	if c.terminal {
+		// clear console colors on close
+		fmt.Fprintln(c.stdout, "\u001b[39m")
+	}
+	return ExitSuccess
+}
+
+func (c *command) startScheduler() {
+	if c.seed != 0 {
+		evaluator.UseDeterministicScheduler(c.seed)
+	}
+}
+
+func (c *command) waitForTasks() {
+	if running := evaluator.WaitForTasks(c.asyncTimeout); running > 0 {
+		fmt.Fprintf(c.stderr, "%d async functions still running after %s, exiting anyway\n", running, c.asyncTimeout)
+	}
+}
+
+func stringArray(values []string) *object.Array {
+	arr := &object.Array{Elements: []object.Object{}}
+	for _, v := range values {
+		arr.Elements = append(arr.Elements, &object.String{Value: v})
+	}
+	return arr
+}
+
+// isTerminal reports whether w is a character device like a terminal, rather than a pipe or file
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"bytes"
+	"donkey/evaluator"
+	"donkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeScript(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCLI(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestExitCodes(t *testing.T) {
+	// `-seed` switches to the deterministic scheduler
+	t.Cleanup(evaluator.UseGoroutineScheduler)
+
+	ok := writeScript(t, "ok.dk", "#!/usr/bin/env donkey\nlet x = 1;\n")
+	broken := writeScript(t, "broken.dk", "let x = ;\n")
+	failing := writeScript(t, "failing.dk", "let x = 1;\nx / 0;\n")
+	badMacro := writeScript(t, "macro.dk", "let m = macro() { 1 };\nm();\n")
+	unformatted := writeScript(t, "unformatted.dk", "let x=1\n")
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"run", ok}, "", ExitSuccess, "", ""},
+		{[]string{ok}, "", ExitSuccess, "", ""},
+		{[]string{"run", broken}, "", ExitParseError, "", broken + ":1:9 >> no prefix parse function for ;"},
+		{[]string{"run", failing}, "", ExitRuntimeError, "", failing + ":2:3 >> division by zero: 1 / 0"},
+		{[]string{"run", badMacro}, "", ExitRuntimeError, "", "macro m must return a quote, got=INTEGER"},
+		{[]string{"run", "missing.dk"}, "", ExitUsageError, "", "missing.dk"},
+		{[]string{"run"}, "1 / 0", ExitRuntimeError, "", "Line: 1, col: 3 >> division by zero"},
+		{[]string{"run", "-", "a"}, "if (len(args) != 1) { 1 / 0 }", ExitSuccess, "", ""},
+		{[]string{"run", "-seed", "7", "-"}, "await async fn() { 1 }()", ExitSuccess, "", ""},
+		{[]string{"run", "-"}, "async fn() { 1 / 0 }();", ExitRuntimeError, "", "unhandled error in async function"},
+		{[]string{"eval", "1 + 2"}, "", ExitSuccess, "3\n", ""},
+		{[]string{"eval", "let x = 1;"}, "", ExitSuccess, "", ""},
+		{[]string{"eval", "1 +"}, "", ExitParseError, "", "Line: 1, col: 4"},
+		{[]string{"eval", "--", "-true"}, "", ExitRuntimeError, "", "unknown operator: -BOOLEAN"},
+		{[]string{"eval", "let f = fn(a, b) { a }; f(1)"}, "", ExitRuntimeError, "", "wrong number of arguments. got=1, want=2"},
+		{[]string{"eval"}, "", ExitUsageError, "", "eval expects exactly one expression"},
+		{[]string{"check", ok, failing}, "", ExitSuccess, "", ""},
+		{[]string{"check", badMacro, broken}, "", ExitParseError, "", "macro m must return a quote"},
+		{[]string{"check", badMacro}, "", ExitRuntimeError, "", ""},
+		{[]string{"check"}, "", ExitUsageError, "", "check expects at least one file"},
+		{[]string{"check", "-seed", "1", ok}, "", ExitUsageError, "", "flag provided but not defined: -seed"},
+		{[]string{"fmt", "-check", ok, failing}, "", ExitSuccess, "", ""},
+		{[]string{"fmt", "-check", ok, unformatted}, "", ExitUnformatted, unformatted + "\n", ""},
+		{[]string{"fmt", "-check", unformatted, broken}, "", ExitParseError, unformatted + "\n", broken + ":1:9"},
+		{[]string{"fmt", "-check"}, "let x=1", ExitUnformatted, "<stdin>\n", ""},
+		{[]string{"fmt"}, "let x=1", ExitSuccess, "let x = 1;\n", ""},
+		{[]string{"fmt", unformatted}, "", ExitSuccess, "let x = 1;\n", ""},
+		{[]string{"fmt", "-w"}, "let x=1", ExitUsageError, "", "fmt -w expects at least one file"},
+		{[]string{"fmt", "-w", "-check", ok}, "", ExitUsageError, "", "either -w or -check"},
+		{[]string{"-h"}, "", ExitSuccess, "", "usage: donkey"},
+		{[]string{"help"}, "", ExitSuccess, "", "exit codes:"},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runCLI(tt.args, tt.stdin)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d, stderr=%q", tt.args, tt.expectedCode, code, stderr)
+		}
+		if stdout != tt.expectedStdout {
+			t.Errorf("%v: wrong stdout. want=%q, got=%q", tt.args, tt.expectedStdout, stdout)
+		}
+		if tt.expectedStderr == "" && tt.expectedCode == ExitSuccess && stderr != "" {
+			t.Errorf("%v: unexpected stderr %q", tt.args, stderr)
+		}
+		if !strings.Contains(stderr, tt.expectedStderr) {
+			t.Errorf("%v: stderr does not contain %q. got=%q", tt.args, tt.expectedStderr, stderr)
+		}
+	}
+}
+
+func TestInterpreterPanic(t *testing.T) {
+	evaluator.RegisterMethod(object.NULL_OBJ, "explode", func(args ...object.Object) object.Object {
+		panic("boom")
+	})
+
+	tests := []struct {
+		input          string
+		expectedStdout string
+		expectedStderr string
+	}{
+		{"let x = if (false) { 1 }; x.explode()", "", "Runtime error: internal error: boom"},
+		// the panic happens on the task's goroutine
+		{"let f = async fn() { let x = if (false) { 1 }; x.explode() }; f(); 1", "1\n",
+			"unhandled error in async function f at line 1, col 63: internal error: boom"},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runCLI([]string{"eval", tt.input}, "")
+		if code != ExitRuntimeError {
+			t.Errorf("%q: wrong exit code. want=%d, got=%d", tt.input, ExitRuntimeError, code)
+		}
+		if stdout != tt.expectedStdout || !strings.Contains(stderr, tt.expectedStderr) {
+			t.Errorf("%q: wrong output. stdout=%q, stderr=%q", tt.input, stdout, stderr)
+		}
+	}
+}
+
+func TestFormatWrite(t *testing.T) {
+	path := writeScript(t, "script.dk", "let add=fn(a,b){a+b}\nadd(1,2)\n")
+
+	code, stdout, stderr := runCLI([]string{"fmt", "-w", path}, "")
+	if code != ExitSuccess || stdout != "" || stderr != "" {
+		t.Fatalf("fmt -w failed. code=%d, stdout=%q, stderr=%q", code, stdout, stderr)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let add = fn(a, b) { a + b };\nadd(1, 2);\n"
+	if string(content) != expected {
+		t.Errorf("wrong file content. want=%q, got=%q", expected, content)
+	}
+}
+
+func TestReplWithoutTerminal(t *testing.T) {
+	code, stdout, _ := runCLI([]string{"repl"}, "let a = 2;\na * 3\n")
+
+	if code != ExitSuccess {
+		t.Fatalf("wrong exit code. got=%d", code)
+	}
+	if strings.Contains(stdout, "\u001b") || strings.Contains(stdout, "Hello") {
+		t.Errorf("colors or greeting written to a non terminal. got=%q", stdout)
+	}
+	if !strings.Contains(stdout, "6\n") {
+		t.Errorf("result missing from output. got=%q", stdout)
+	}
+}
@@ -1,7 +1,18 @@
 package constants
 
+import "time"
+
 const LangName = "donkey"
 
-const ReplPrompt = "\u001b[33m💡 >> \u001b[0m"
+const ReplPrompt = "💡 >> "
+
+// ReplContinuationPrompt is shown while brackets or strings of the input are still open
+const ReplContinuationPrompt = "   .. "
+
+// ReplPasteMessage is shown when the REPL enters paste mode
+const ReplPasteMessage = "// paste mode, finish with :end or discard with :cancel\n"
 
 const ParserErrorPrompt = "🚨 parser errors:\n"
+
+// AsyncTaskTimeout is how long to wait for running async functions before exiting
+const AsyncTaskTimeout = 5 * time.Second
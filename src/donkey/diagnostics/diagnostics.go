@@ -0,0 +1,161 @@
+package diagnostics
+
+import (
+	"bytes"
+	"donkey/object"
+	"donkey/parser"
+	"donkey/token"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// sources holds the code of inputs that are not read from files, by the pseudo file name their locations refer to
+var sources = struct {
+	mu   sync.RWMutex
+	code map[string]string
+}{code: map[string]string{}}
+
+// RegisterSource keeps the code of an input that is not a file, e.g. an entry of the REPL named `<repl:3>`,
+// so locations in it can be shown after later inputs replaced it.
+func RegisterSource(name, code string) {
+	sources.mu.Lock()
+	defer sources.mu.Unlock()
+
+	sources.code[name] = code
+}
+
+// Render returns the message followed by the source around the location, e.g.
+//
+//	Line: 2, col: 9 >> identifier not found: foo
+//	  1 | let x = 1;
+//	  2 | let z = foo + x;
+//	    |         ^~~
+//	  3 | z
+//
+// Locations in files name the file instead, like `lib.dk:2:9 >> ...`, and their code is read from it
+// or taken from the registered sources. The code is left out if it can not be found.
+func Render(source string, loc token.TokenLocation, message string) string {
+	header := fmt.Sprintf("Line: %d, col: %d >> %s", loc.Line, loc.Column, message)
+	if loc.File != "" {
+		header = fmt.Sprintf("%s:%d:%d >> %s", loc.File, loc.Line, loc.Column, message)
+	}
+
+	code, ok := sourceOf(source, loc)
+	if !ok {
+		return header
+	}
+	snippet := Snippet(code, loc)
+	if snippet == "" {
+		return header
+	}
+	return header + "\n" + snippet
+}
+
+// sourceOf returns the code the location points into, which is its file if it has one.
+// It reports false if the file can not be found.
+func sourceOf(source string, loc token.TokenLocation) (string, bool) {
+	if loc.File == "" {
+		return source, true
+	}
+
+	sources.mu.RLock()
+	code, ok := sources.code[loc.File]
+	sources.mu.RUnlock()
+	if ok {
+		return code, true
+	}
+
+	content, err := os.ReadFile(loc.File)
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+// Snippet returns the line the location starts on, underlined with `^~~~`, and one line of context above and below.
+// It is empty if the location lies outside of the source.
+func Snippet(source string, loc token.TokenLocation) string {
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	idx := loc.Line - 1
+	if idx < 0 || idx >= len(lines) {
+		return ""
+	}
+
+	first, last := max(idx-1, 0), min(idx+1, len(lines)-1)
+	width := len(fmt.Sprint(last + 1))
+
+	var out bytes.Buffer
+	for i := first; i <= last; i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		fmt.Fprintf(&out, "%*d | %s\n", width, i+1, line)
+		if i == idx {
+			fmt.Fprintf(&out, "%*s | %s\n", width, "", underline(line, loc))
+		}
+	}
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// underline marks the location on its first line. Tabs before it are kept, so the marker lines up with the code.
+func underline(line string, loc token.TokenLocation) string {
+	var out strings.Builder
+
+	col := 1
+	for _, char := range line {
+		if col >= loc.Column {
+			break
+		}
+		if char == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+		col++
+	}
+	for ; col < loc.Column; col++ {
+		out.WriteRune(' ')
+	}
+
+	length := 1
+	if loc.EndLine == loc.Line && loc.EndColumn > loc.Column {
+		length = loc.EndColumn - loc.Column
+	} else if loc.EndLine > loc.Line {
+		// locations spanning several lines are underlined until the end of the first one
+		length = max(utf8.RuneCountInString(line)-loc.Column+1, 1)
+	}
+
+	out.WriteString("^" + strings.Repeat("~", length-1))
+	return out.String()
+}
+
+// RenderParseError shows the code the parser choked on
+func RenderParseError(source string, err *parser.ParseError) string {
+	return Render(source, err.Start, err.Message)
+}
+
+// RenderRuntimeError shows the code the error occurred at, followed by its causes and its traceback.
+// Errors macros failed with are rendered the same way.
+func RenderRuntimeError(source string, err *object.Error) string {
+	var out bytes.Buffer
+
+	out.WriteString("Runtime error: ")
+	out.WriteString(renderError(source, err))
+	for _, cause := range err.Causes {
+		out.WriteString("\n" + renderError(source, cause))
+	}
+
+	if traceback := err.Traceback(); traceback != "" {
+		out.WriteString("\n" + traceback)
+	}
+	return out.String()
+}
+
+// renderError shows the message of the error with the code it occurred at, if it has a location
+func renderError(source string, err *object.Error) string {
+	if err.Location == nil {
+		return err.Message
+	}
+	return Render(source, *err.Location, err.Message)
+}
@@ -0,0 +1,196 @@
+package diagnostics
+
+import (
+	"donkey/lexer"
+	"donkey/object"
+	"donkey/parser"
+	"donkey/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	source := "let x = 1;\nlet z = foo + x;\nz"
+
+	tests := []struct {
+		name     string
+		loc      token.TokenLocation
+		expected string
+	}{
+		{
+			"single character",
+			token.TokenLocation{Line: 2, Column: 13, EndLine: 2, EndColumn: 14},
+			"Line: 2, col: 13 >> oops\n" +
+				"1 | let x = 1;\n" +
+				"2 | let z = foo + x;\n" +
+				"  |             ^\n" +
+				"3 | z",
+		},
+		{
+			"token",
+			token.TokenLocation{Line: 2, Column: 9, EndLine: 2, EndColumn: 12},
+			"Line: 2, col: 9 >> oops\n" +
+				"1 | let x = 1;\n" +
+				"2 | let z = foo + x;\n" +
+				"  |         ^~~\n" +
+				"3 | z",
+		},
+		{
+			"first line",
+			token.TokenLocation{Line: 1, Column: 5, EndLine: 1, EndColumn: 6},
+			"Line: 1, col: 5 >> oops\n" +
+				"1 | let x = 1;\n" +
+				"  |     ^\n" +
+				"2 | let z = foo + x;",
+		},
+		{
+			"last line",
+			token.TokenLocation{Line: 3, Column: 1, EndLine: 3, EndColumn: 2},
+			"Line: 3, col: 1 >> oops\n" +
+				"2 | let z = foo + x;\n" +
+				"3 | z\n" +
+				"  | ^",
+		},
+		{
+			"multiple lines",
+			token.TokenLocation{Line: 2, Column: 9, EndLine: 3, EndColumn: 2},
+			"Line: 2, col: 9 >> oops\n" +
+				"1 | let x = 1;\n" +
+				"2 | let z = foo + x;\n" +
+				"  |         ^~~~~~~~\n" +
+				"3 | z",
+		},
+		{
+			"outside of the source",
+			token.TokenLocation{Line: 7, Column: 1, EndLine: 7, EndColumn: 2},
+			"Line: 7, col: 1 >> oops",
+		},
+	}
+
+	for _, tt := range tests {
+		rendered := Render(source, tt.loc, "oops")
+		if rendered != tt.expected {
+			t.Errorf("%s: wrong rendering.\nwant=\n%s\ngot=\n%s", tt.name, tt.expected, rendered)
+		}
+	}
+}
+
+func TestRenderKeepsTabsAndWidensGutter(t *testing.T) {
+	source := "1;\n2;\n3;\n4;\n5;\n6;\n7;\n8;\n9;\n\tlet a = b;\n11;"
+	loc := token.TokenLocation{Line: 10, Column: 10, EndLine: 10, EndColumn: 11}
+
+	expected := " 9 | 9;\n" +
+		"10 | \tlet a = b;\n" +
+		"   | \t        ^\n" +
+		"11 | 11;"
+
+	if snippet := Snippet(source, loc); snippet != expected {
+		t.Errorf("wrong snippet.\nwant=\n%s\ngot=\n%s", expected, snippet)
+	}
+}
+
+func TestRenderReadsFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.dk")
+	if err := os.WriteFile(path, []byte("let half = fn(x) {\n\tx / zero\n};\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loc := token.TokenLocation{File: path, Line: 2, Column: 6, EndLine: 2, EndColumn: 10}
+	expected := path + ":2:6 >> identifier not found: zero\n" +
+		"1 | let half = fn(x) {\n" +
+		"2 | \tx / zero\n" +
+		"  | \t    ^~~~\n" +
+		"3 | };"
+
+	if rendered := Render("other source", loc, "identifier not found: zero"); rendered != expected {
+		t.Errorf("wrong rendering.\nwant=\n%s\ngot=\n%s", expected, rendered)
+	}
+}
+
+func TestRenderRegisteredSource(t *testing.T) {
+	RegisterSource("<repl:1>", "let f = fn() { missing }")
+
+	loc := token.TokenLocation{File: "<repl:1>", Line: 1, Column: 16, EndLine: 1, EndColumn: 23}
+	expected := "<repl:1>:1:16 >> identifier not found: missing\n" +
+		"1 | let f = fn() { missing }\n" +
+		"  |                ^~~~~~~"
+
+	if rendered := Render("f()", loc, "identifier not found: missing"); rendered != expected {
+		t.Errorf("wrong rendering.\nwant=\n%s\ngot=\n%s", expected, rendered)
+	}
+
+	// the code of unknown files is left out rather than taken from the wrong source
+	loc.File = "<repl:unknown>"
+	expected = "<repl:unknown>:1:16 >> identifier not found: missing"
+	if rendered := Render("f()", loc, "identifier not found: missing"); rendered != expected {
+		t.Errorf("wrong rendering of unknown file.\nwant=\n%s\ngot=\n%s", expected, rendered)
+	}
+}
+
+func TestRenderParseError(t *testing.T) {
+	source := "let a = 1;\nlet = 5;"
+
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parse errors")
+	}
+
+	expected := "Line: 2, col: 5 >> expected next token to be IDENT, got = instead\n" +
+		"1 | let a = 1;\n" +
+		"2 | let = 5;\n" +
+		"  |     ^"
+
+	if rendered := RenderParseError(source, p.Errors()[0]); rendered != expected {
+		t.Errorf("wrong rendering.\nwant=\n%s\ngot=\n%s", expected, rendered)
+	}
+}
+
+func TestRenderRuntimeError(t *testing.T) {
+	source := "let add = fn(a) { a + missing };\nadd(1);"
+
+	err := &object.Error{
+		Message:  "identifier not found: missing",
+		Location: &token.TokenLocation{Line: 1, Column: 23, EndLine: 1, EndColumn: 30},
+		Stack:    []object.Frame{{Function: "add", Location: &token.TokenLocation{Line: 2, Column: 1}}},
+	}
+
+	expected := "Runtime error: Line: 1, col: 23 >> identifier not found: missing\n" +
+		"1 | let add = fn(a) { a + missing };\n" +
+		"  |                       ^~~~~~~\n" +
+		"2 | add(1);\n" +
+		"Traceback (most recent call first):\n" +
+		"\tin add, called at line 2, col 1"
+
+	if rendered := RenderRuntimeError(source, err); rendered != expected {
+		t.Errorf("wrong rendering.\nwant=\n%s\ngot=\n%s", expected, rendered)
+	}
+
+	err = &object.Error{
+		Message:  "parse errors in module lib.dk",
+		Location: &token.TokenLocation{Line: 2, Column: 1, EndLine: 2, EndColumn: 4},
+		Causes: []*object.Error{{
+			Message:  "expected next token to be IDENT, got = instead",
+			Location: &token.TokenLocation{File: "<lib>", Line: 1, Column: 5, EndLine: 1, EndColumn: 6},
+		}},
+	}
+	RegisterSource("<lib>", "let = 5;")
+
+	expected = "Runtime error: Line: 2, col: 1 >> parse errors in module lib.dk\n" +
+		"1 | let add = fn(a) { a + missing };\n" +
+		"2 | add(1);\n" +
+		"  | ^~~\n" +
+		"<lib>:1:5 >> expected next token to be IDENT, got = instead\n" +
+		"1 | let = 5;\n" +
+		"  |     ^"
+
+	if rendered := RenderRuntimeError(source, err); rendered != expected {
+		t.Errorf("wrong rendering of causes.\nwant=\n%s\ngot=\n%s", expected, rendered)
+	}
+
+	err = &object.Error{Message: "deadlock"}
+	if rendered := RenderRuntimeError(source, err); rendered != "Runtime error: deadlock" {
+		t.Errorf("wrong rendering without location. got=%q", rendered)
+	}
+}
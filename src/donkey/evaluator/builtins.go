@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"sync"
 )
 
 var builtins = map[string]*object.Builtin{
@@ -15,6 +17,21 @@ var builtins = map[string]*object.Builtin{
 	"push":  builtinPush(),
 	"print": builtinPrint(),
 	"fetch": builtinFetch(),
+	"range": builtinRange(),
+	"all":   builtinAll(),
+	"race":  builtinRace(),
+
+	"channel": builtinChannel(),
+}
+
+// BuiltinNames returns the names of all builtin functions in alphabetical order
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func builtinLen() *object.Builtin {
@@ -29,7 +46,10 @@ func builtinLen() *object.Builtin {
 				return &object.Integer{Value: int64(len(arg.Value))}
 
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return &object.Integer{Value: int64(arg.Len())}
+
+			case *object.Range:
+				return &object.Integer{Value: arg.Len()}
 
 			default:
 				return newError("argument to `len` not supported, got=%s", nil, args[0].Type())
@@ -49,9 +69,8 @@ func builtinFirst() *object.Builtin {
 				return newError("argument to `first` must be ARRAY, got=%s", nil, args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+			if first, ok := args[0].(*object.Array).Index(0); ok {
+				return first
 			}
 
 			return NULL
@@ -70,10 +89,8 @@ func builtinLast() *object.Builtin {
 				return newError("argument to `last` must be ARRAY, got=%s", nil, args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
+			if last, ok := args[0].(*object.Array).Index(-1); ok {
+				return last
 			}
 
 			return NULL
@@ -91,12 +108,9 @@ func builtinRest() *object.Builtin {
 				return newError("argument to `rest` must be ARRAY, got %s", nil, args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
+			elements := args[0].(*object.Array).Snapshot()
+			if len(elements) > 0 {
+				return &object.Array{Elements: elements[1:]}
 			}
 
 			return NULL
@@ -114,13 +128,7 @@ func builtinPush() *object.Builtin {
 				return newError("argument to `push` must be ARRAY, got %s", nil, args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-
-			newElements := make([]object.Object, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-
+			newElements := append(args[0].(*object.Array).Snapshot(), args[1])
 			return &object.Array{Elements: newElements}
 		},
 	}
@@ -165,3 +173,149 @@ func builtinFetch() *object.Builtin {
 		},
 	}
 }
+
+// builtinRange supports range(end), range(start, end) and range(start, end, step)
+func builtinRange() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1..3", nil, len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("arguments to `range` must be INTEGER, got=%s", nil, arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			r := &object.Range{Start: 0, Step: 1}
+			switch len(bounds) {
+			case 1:
+				r.End = bounds[0]
+			case 2:
+				r.Start, r.End = bounds[0], bounds[1]
+			case 3:
+				r.Start, r.End, r.Step = bounds[0], bounds[1], bounds[2]
+			}
+
+			if r.Step == 0 {
+				return newError("`range` step must not be 0", nil)
+			}
+
+			return r
+		},
+	}
+}
+
+// futuresArg turns the array argument of `all` and `race` into futures.
+// Plain values are treated as already resolved futures.
+func futuresArg(name string, args []object.Object) ([]*object.Future, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", nil, len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got=%s", nil, name, args[0].Type())
+	}
+
+	elements := arr.Snapshot()
+	futures := make([]*object.Future, len(elements))
+	for i, el := range elements {
+		future, ok := el.(*object.Future)
+		if !ok {
+			future = object.NewFuture()
+			future.Resolve(el)
+		}
+		futures[i] = future
+	}
+	return futures, nil
+}
+
+// builtinAll returns a future resolving to the array of all results, or to the first error that occurs
+func builtinAll() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			futures, err := futuresArg("all", args)
+			if err != nil {
+				return err
+			}
+
+			all := object.NewFuture()
+			if len(futures) == 0 {
+				all.Resolve(&object.Array{Elements: []object.Object{}})
+				return all
+			}
+
+			var mu sync.Mutex
+			results := make([]object.Object, len(futures))
+			remaining := len(futures)
+			for i, future := range futures {
+				i := i
+				future.OnResolve(func(res object.Object) {
+					if isError(res) {
+						all.Resolve(res)
+						return
+					}
+
+					mu.Lock()
+					results[i] = res
+					remaining--
+					done := remaining == 0
+					mu.Unlock()
+
+					if done {
+						all.Resolve(&object.Array{Elements: results})
+					}
+				})
+			}
+			return all
+		},
+	}
+}
+
+// builtinRace returns a future resolving to the result of whichever future finishes first, even if that is an error
+func builtinRace() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			futures, err := futuresArg("race", args)
+			if err != nil {
+				return err
+			}
+			if len(futures) == 0 {
+				return newError("`race` needs at least one future", nil)
+			}
+
+			race := object.NewFuture()
+			for _, future := range futures {
+				future.OnResolve(race.Resolve)
+			}
+			return race
+		},
+	}
+}
+
+// builtinChannel supports channel() for unbuffered and channel(capacity) for buffered channels
+func builtinChannel() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0..1", nil, len(args))
+			}
+			if len(args) == 0 {
+				return object.NewChannel(0)
+			}
+
+			capacity, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `channel` must be INTEGER, got=%s", nil, args[0].Type())
+			}
+			if capacity.Value < 0 {
+				return newError("`channel` capacity must not be negative, got=%d", nil, capacity.Value)
+			}
+			return object.NewChannel(int(capacity.Value))
+		},
+	}
+}
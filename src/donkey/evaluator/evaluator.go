@@ -5,6 +5,8 @@ import (
 	"donkey/object"
 	"donkey/token"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 )
 
@@ -13,6 +15,9 @@ var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 // TODO: potentially replace passing the location around with a context (containing the location) instead
@@ -24,9 +29,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalProgram(node.Statements, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isError(val) || isInterruption(val) {
 			return val
 		}
+		// functions are anonymous values, the first binding names them for error reports
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
@@ -34,41 +43,65 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isError(val) || isInterruption(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 
 	// Expressions
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isError(right) || isInterruption(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right, &node.Token.Location)
+	case *ast.AwaitExpression:
+		return evalAwaitExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isError(left) || isInterruption(left) {
 			return left
 		}
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isError(right) || isInterruption(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right, &node.Token.Location)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isError(left) || isInterruption(left) {
 			return left
 		}
 		idx := Eval(node.Index, env)
-		if isError(idx) {
+		if isError(idx) || isInterruption(idx) {
 			return idx
 		}
 		return evalIndexExpression(left, idx, &node.Token.Location)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) || isInterruption(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Property.Value, &node.Token.Location)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.ImportExpression:
+		return evalImportExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.CallExpression:
@@ -77,16 +110,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		fn := Eval(node.Function, env)
-		if isError(fn) {
+		if isError(fn) || isInterruption(fn) {
 			return fn
 		}
 		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && (isError(args[0]) || isInterruption(args[0])) {
 			return args[0]
 		}
-		res := applyFunction(fn, &node.Token.Location, args)
-		if isError(res) {
-			res.(*object.Error).Location = &node.Token.Location
+		// calls are located at the whole expression, like the renderer underlines it, not at the `(`
+		loc := node.Span()
+		res := applyFunction(fn, &loc, args)
+		// builtins do not know where they were called from
+		if err, ok := res.(*object.Error); ok && err.Location == nil {
+			err.Location = &loc
 		}
 		return res
 
@@ -95,11 +131,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && (isError(elements[0]) || isInterruption(elements[0])) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
@@ -118,6 +158,7 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range stmts {
+		object.Yield()
 		result = Eval(stmt, env)
 
 		switch result := result.(type) {
@@ -131,18 +172,15 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 }
 
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	if block.Async {
-		return evalAsyncBlockStatement(block, env)
-	}
-
 	var res object.Object
 
 	for _, stmt := range block.Statements {
+		object.Yield()
 		res = Eval(stmt, env)
 
 		if res != nil {
 			rt := res.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return res
 			}
 		}
@@ -150,25 +188,29 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return res
 }
 
-func evalAsyncBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	go func() {
-		var res object.Object
-		for _, stmt := range block.Statements {
-			res = Eval(stmt, env)
+// evalAwaitExpression blocks until the future is resolved. Awaiting any other value returns it unchanged.
+func evalAwaitExpression(ae *ast.AwaitExpression, env *object.Environment) object.Object {
+	val := Eval(ae.Value, env)
+	if isError(val) || isInterruption(val) {
+		return val
+	}
 
-			if res != nil {
-				rt := res.Type()
-				if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-					fmt.Printf("Async function tried to return. %s TODO message\n", res)
-				}
-			}
-		}
+	future, ok := val.(*object.Future)
+	if !ok {
+		return val
+	}
 
-		if res != nil {
-			fmt.Println("Async function tried to return via expression. TODO message")
+	res := future.Wait()
+	if err, ok := res.(*object.Error); ok {
+		// the result is shared by everyone awaiting the future, but callers extend the stack
+		shared := *err
+		shared.Stack = append([]object.Frame{}, err.Stack...)
+		if shared.Location == nil {
+			shared.Location = &ae.Token.Location
 		}
-	}()
-	return NULL
+		return &shared
+	}
+	return res
 }
 
 // ____________
@@ -181,7 +223,7 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 	for _, exp := range exps {
 		evaled := Eval(exp, env)
-		if isError(evaled) {
+		if isError(evaled) || isInterruption(evaled) {
 			return []object.Object{evaled}
 		}
 		result = append(result, evaled)
@@ -215,11 +257,14 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusOperatorExpression(right object.Object, loc *token.TokenLocation) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", loc, right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -241,7 +286,86 @@ func evalIntegerInfixExpression(operator string, left, right object.Object, loc
 	case "*":
 		return &object.Integer{Value: lVal * rVal}
 	case "/":
+		if rVal == 0 {
+			return newError("division by zero: %d / %d", loc, lVal, rVal)
+		}
 		return &object.Integer{Value: lVal / rVal}
+	case "%":
+		if rVal == 0 {
+			return newError("division by zero: %d %% %d", loc, lVal, rVal)
+		}
+		return &object.Integer{Value: lVal % rVal}
+	case "**":
+		if rVal < 0 {
+			return &object.Float{Value: math.Pow(float64(lVal), float64(rVal))}
+		}
+		return &object.Integer{Value: intPow(lVal, rVal)}
+
+	case "&":
+		return &object.Integer{Value: lVal & rVal}
+	case "|":
+		return &object.Integer{Value: lVal | rVal}
+	case "^":
+		return &object.Integer{Value: lVal ^ rVal}
+	case "<<":
+		if rVal < 0 {
+			return newError("negative shift count: %d", loc, rVal)
+		}
+		return &object.Integer{Value: lVal << rVal}
+	case ">>":
+		if rVal < 0 {
+			return newError("negative shift count: %d", loc, rVal)
+		}
+		return &object.Integer{Value: lVal >> rVal}
+
+	case "<":
+		return nativeBoolToBooleanObject(lVal < rVal)
+	case "<=":
+		return nativeBoolToBooleanObject(lVal <= rVal)
+	case ">":
+		return nativeBoolToBooleanObject(lVal > rVal)
+	case ">=":
+		return nativeBoolToBooleanObject(lVal >= rVal)
+	case "==":
+		return nativeBoolToBooleanObject(lVal == rVal)
+	case "!=":
+		return nativeBoolToBooleanObject(lVal != rVal)
+	default:
+		return newError("unknown operator: %s %s %s", loc, left.Type(), operator, right.Type())
+	}
+}
+
+// intPow computes base ** exp for a non-negative exponent by squaring
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// evalFloatInfixExpression handles float and mixed int/float operands, integers are widened to floats
+func evalFloatInfixExpression(operator string, left, right object.Object, loc *token.TokenLocation) object.Object {
+	lVal := toFloat(left)
+	rVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: lVal + rVal}
+	case "-":
+		return &object.Float{Value: lVal - rVal}
+	case "*":
+		return &object.Float{Value: lVal * rVal}
+	case "/":
+		return &object.Float{Value: lVal / rVal}
+	case "%":
+		return &object.Float{Value: math.Mod(lVal, rVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(lVal, rVal)}
 
 	case "<":
 		return nativeBoolToBooleanObject(lVal < rVal)
@@ -278,10 +402,33 @@ func evalStringInfixExpression(operator string, left, right object.Object, loc *
 	}
 }
 
+// evalLogicalExpression evaluates && and ||, the right side is only evaluated if it decides the result
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) || isInterruption(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) || isInterruption(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 func evalInfixExpression(operator string, left, right object.Object, loc *token.TokenLocation) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right, loc)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right, loc)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right, loc)
 
@@ -303,21 +450,12 @@ func evalArrayIndexExpression(arr object.Object, idx object.Object, loc *token.T
 		return newError("type mismatch for array index operation. got=%s", loc, arr.Type())
 	}
 
-	i := idx.(*object.Integer).Value
-	maxIdx := int64(len(ao.Elements) - 1)
-
-	// check upper boundary for positive and negative index
-	// [1,2,3][3] || [1,2,3][-4]
-	if i > maxIdx || -i > maxIdx+1 {
+	// negative indices access from the back, [1,2,3][-1] --> 3
+	el, ok := ao.Index(idx.(*object.Integer).Value)
+	if !ok {
 		return NULL
 	}
-
-	access := i
-	// support access from back [1,2,3][-1] --> 3
-	if i < 0 {
-		access += maxIdx + 1
-	}
-	return ao.Elements[access]
+	return el
 }
 
 func evalHashIndexExpression(hash object.Object, idx object.Object, loc *token.TokenLocation) object.Object {
@@ -334,7 +472,7 @@ func evalHashIndexExpression(hash object.Object, idx object.Object, loc *token.T
 
 	hashed := hashKey.HashKey()
 
-	pair, ok := ho.Pairs[hashed]
+	pair, ok := ho.Get(hashed)
 	if !ok {
 		return NULL
 	}
@@ -348,22 +486,295 @@ func evalIndexExpression(left object.Object, index object.Object, loc *token.Tok
 		return evalArrayIndexExpression(left, index, loc)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index, loc)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalModuleMember(left.(*object.Module), index.(*object.String).Value, loc)
 	default:
 		return newError("index operator not supported: %s", loc, left.Type())
 	}
 }
 
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) || isInterruption(val) {
+		return val
+	}
+
+	loc := &node.Token.Location
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		updated, ok := env.Modify(target.Value, assignment(node.Operator, val, loc))
+		if !ok {
+			return newError("assignment to undeclared identifier: %s", loc, target.Value)
+		}
+		return updated
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) || isInterruption(left) {
+			return left
+		}
+		idx := Eval(target.Index, env)
+		if isError(idx) || isInterruption(idx) {
+			return idx
+		}
+
+		return evalIndexAssignment(left, idx, assignment(node.Operator, val, loc), loc)
+
+	case *ast.MemberExpression:
+		obj := Eval(target.Object, env)
+		if isError(obj) || isInterruption(obj) {
+			return obj
+		}
+		if obj.Type() != object.HASH_OBJ {
+			return newError("member assignment not supported: %s", loc, obj.Type())
+		}
+		key := &object.String{Value: target.Property.Value}
+
+		return evalIndexAssignment(obj, key, assignment(node.Operator, val, loc), loc)
+
+	default:
+		return newError("cannot assign to %s", loc, node.Target.String())
+	}
+}
+
+// assignment returns the new value of an assignment's target given its current one. For `=` that is val,
+// compound assignments apply their infix operator, e.g. `+` for `+=`, to the current value and val.
+// It runs while the target is locked, so it must not evaluate code.
+func assignment(operator string, val object.Object, loc *token.TokenLocation) func(current object.Object) object.Object {
+	if operator == "=" {
+		return func(current object.Object) object.Object { return val }
+	}
+	return func(current object.Object) object.Object {
+		if current == nil {
+			current = NULL
+		}
+		return evalInfixExpression(strings.TrimSuffix(operator, "="), current, val, loc)
+	}
+}
+
+func evalIndexAssignment(left, idx object.Object, assign func(current object.Object) object.Object, loc *token.TokenLocation) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := idx.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got=%s", loc, idx.Type())
+		}
+
+		val, ok := left.Modify(i.Value, assign)
+		if !ok {
+			return newError("array index out of range: %d", loc, i.Value)
+		}
+		return val
+
+	case *object.Hash:
+		hashKey, ok := idx.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", loc, idx.Type())
+		}
+
+		return left.Modify(idx, hashKey.HashKey(), assign)
+
+	default:
+		return newError("index assignment not supported: %s", loc, left.Type())
+	}
+}
+
+// evalMemberExpression resolves `obj.name`. For hashes it is sugar for `obj["name"]`,
+// otherwise and for hashes without that key it looks up a method of the object's type.
+func evalMemberExpression(obj object.Object, name string, loc *token.TokenLocation) object.Object {
+	if module, ok := obj.(*object.Module); ok {
+		return evalModuleMember(module, name, loc)
+	}
+
+	if hash, ok := obj.(*object.Hash); ok {
+		if pair, ok := hash.Get((&object.String{Value: name}).HashKey()); ok {
+			return pair.Value
+		}
+	}
+
+	if method, ok := lookupMethod(obj, name); ok {
+		return method
+	}
+
+	if obj.Type() == object.HASH_OBJ {
+		return NULL
+	}
+	return newError("unknown member %s on %s", loc, name, obj.Type())
+}
+
+// evalSelectExpression evaluates all channels and sent values up front, then performs one ready operation
+// and evaluates its case body. The received value is bound to the case's name within that body.
+func evalSelectExpression(se *ast.SelectExpression, env *object.Environment) object.Object {
+	loc := &se.Token.Location
+
+	ops := make([]object.ChannelOp, len(se.Cases))
+	for i, c := range se.Cases {
+		val := Eval(c.Channel, env)
+		if isError(val) || isInterruption(val) {
+			return val
+		}
+		channel, ok := val.(*object.Channel)
+		if !ok {
+			return newError("select case needs a CHANNEL, got=%s", &c.Token.Location, val.Type())
+		}
+		ops[i].Channel = channel
+
+		if c.Value != nil {
+			sent := Eval(c.Value, env)
+			if isError(sent) || isInterruption(sent) {
+				return sent
+			}
+			ops[i].Value = sent
+		}
+	}
+
+	chosen, res := object.Select(ops, se.Default != nil)
+	if err, ok := res.(*object.Error); ok {
+		if err.Location == nil {
+			err.Location = loc
+		}
+		return err
+	}
+
+	if chosen == -1 {
+		return Eval(se.Default, object.NewEnclosedEnvironment(env))
+	}
+
+	selected := se.Cases[chosen]
+	caseEnv := object.NewEnclosedEnvironment(env)
+	if selected.Name != nil {
+		if res == nil {
+			res = NULL
+		}
+		caseEnv.Set(selected.Name.Value, res)
+	}
+	return Eval(selected.Body, caseEnv)
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 
+	if isError(condition) || isInterruption(condition) {
+		return condition
+	}
+
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
+	} else if ie.AlternativeIf != nil {
+		return Eval(ie.AlternativeIf, env)
 	} else if ie.Alternative != nil {
 		return Eval(ie.Alternative, env)
 	}
 	return NULL
 }
 
+func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	if fe.Iterable == nil {
+		return evalConditionLoop(fe, env)
+	}
+
+	iterable := Eval(fe.Iterable, env)
+	if isError(iterable) || isInterruption(iterable) {
+		return iterable
+	}
+
+	// every iteration gets its own scope, so closures capture the current loop variables
+	body := func(key, value object.Object) (object.Object, bool) {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if fe.Key != nil {
+			loopEnv.Set(fe.Key.Value, key)
+		}
+		loopEnv.Set(fe.Value.Value, value)
+
+		return evalLoopBody(fe.Body, loopEnv)
+	}
+
+	switch it := iterable.(type) {
+	case *object.Array:
+		// the loop runs over the elements the array had when it started
+		for i, el := range it.Snapshot() {
+			if res, stop := body(&object.Integer{Value: int64(i)}, el); stop {
+				return res
+			}
+		}
+
+	case *object.String:
+		i := 0
+		for _, r := range it.Value {
+			if res, stop := body(&object.Integer{Value: int64(i)}, &object.String{Value: string(r)}); stop {
+				return res
+			}
+			i++
+		}
+
+	case *object.Hash:
+		for _, pair := range sortedHashPairs(it) {
+			var res object.Object
+			var stop bool
+			if fe.Key != nil {
+				res, stop = body(pair.Key, pair.Value)
+			} else {
+				res, stop = body(nil, pair.Key)
+			}
+			if stop {
+				return res
+			}
+		}
+
+	case *object.Range:
+		var i int64
+		for n := it.Start; (it.Step > 0 && n < it.End) || (it.Step < 0 && n > it.End); n += it.Step {
+			if res, stop := body(&object.Integer{Value: i}, &object.Integer{Value: n}); stop {
+				return res
+			}
+			i++
+			// stop before the next step would overflow past the end
+			if (it.Step > 0 && n >= it.End-it.Step) || (it.Step < 0 && n <= it.End-it.Step) {
+				break
+			}
+		}
+
+	default:
+		return newError("for-in not supported for: %s", &fe.Token.Location, iterable.Type())
+	}
+
+	return NULL
+}
+
+func evalConditionLoop(fe *ast.ForExpression, env *object.Environment) object.Object {
+	for {
+		condition := Eval(fe.Condition, env)
+		if isError(condition) || isInterruption(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		if res, stop := evalLoopBody(fe.Body, object.NewEnclosedEnvironment(env)); stop {
+			return res
+		}
+	}
+}
+
+// evalLoopBody evaluates a single iteration and reports whether the loop has to stop.
+// The returned object is the result of the whole loop in that case.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	res := Eval(body, env)
+	if res == nil {
+		return nil, false
+	}
+
+	switch res.Type() {
+	case object.BREAK_OBJ:
+		return NULL, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return res, true
+	}
+	return nil, false
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -374,11 +785,29 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: "+node.Value, &node.Token.Location)
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for i, text := range node.Texts {
+		out.WriteString(text)
+
+		if i < len(node.Expressions) {
+			val := Eval(node.Expressions[i], env)
+			if isError(val) || isInterruption(val) {
+				return val
+			}
+			out.WriteString(val.Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if isError(key) || isInterruption(key) {
 			return key
 		}
 
@@ -388,7 +817,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		}
 
 		value := Eval(valueNode, env)
-		if isError(value) {
+		if isError(value) || isInterruption(value) {
 			return value
 		}
 
@@ -404,6 +833,22 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 // Utility stuff
 // ____________
 
+// sortedHashPairs returns the hash pairs in a stable order, so iterating a hash is deterministic
+func sortedHashPairs(hash *object.Hash) []object.HashPair {
+	pairs := hash.Snapshot()
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key, pairs[j].Key
+		if a.Type() != b.Type() {
+			return a.Type() < b.Type()
+		}
+		if ai, ok := a.(*object.Integer); ok {
+			return ai.Value < b.(*object.Integer).Value
+		}
+		return a.Inspect() < b.Inspect()
+	})
+	return pairs
+}
+
 // isTruthy returns false only if object is NULL or FALSE, everything else is true
 func isTruthy(obj object.Object) bool {
 	switch obj {
@@ -418,6 +863,18 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat widens a number object to float64, callers have to ensure isNumber(obj)
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func newError(format string, location *token.TokenLocation, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...), Location: location}
 }
@@ -429,12 +886,30 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isInterruption reports whether obj is a return, break or continue that has to leave the enclosing block
+func isInterruption(obj object.Object) bool {
+	if obj != nil {
+		rt := obj.Type()
+		return rt == object.RETURN_VALUE_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ
+	}
+	return false
+}
+
 func applyFunction(fn object.Object, loc *token.TokenLocation, args []object.Object) object.Object {
 	switch fun := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fun, args)
-		evaled := Eval(fun.Body, extendedEnv)
-		return unwrapReturnValue(evaled)
+		extendedEnv, err := extendFunctionEnv(fun, args, loc)
+		if err != nil {
+			return err
+		}
+		if fun.Body.Async {
+			return spawnTask(fun, extendedEnv, loc)
+		}
+		evaled := unwrapReturnValue(Eval(fun.Body, extendedEnv))
+		if err, ok := evaled.(*object.Error); ok {
+			err.Stack = append(err.Stack, object.Frame{Function: functionName(fun), Location: loc})
+		}
+		return evaled
 
 	case *object.Builtin:
 		return fun.Fn(args...)
@@ -444,14 +919,26 @@ func applyFunction(fn object.Object, loc *token.TokenLocation, args []object.Obj
 	}
 }
 
+// functionName is the name of the binding the function was first assigned to
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
 // copies existing env values over to new one
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+func extendFunctionEnv(fn *object.Function, args []object.Object, loc *token.TokenLocation) (*object.Environment, *object.Error) {
+	if len(args) != len(fn.Parameters) {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", loc, len(args), len(fn.Parameters))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
 	}
-	return env
+	return env, nil
 }
 
 // we need to unwrap it otherwise a return statement would bubble up and stop the evaluation
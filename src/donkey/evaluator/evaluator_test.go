@@ -4,6 +4,10 @@ import (
 	"donkey/lexer"
 	"donkey/object"
 	"donkey/parser"
+	"donkey/token"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -27,6 +31,18 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"1 | 2 ^ 3 & 4 << 1", 3},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +51,65 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"0.1 + 0.2 * 10", 2.1},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"7 / 2.0", 3.5},
+		{"3 * 1.5 - 1", 3.5},
+		{"1e3 / 4", 250},
+		{"let price = 19.99; price *= 2; price", 39.98},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"7.5 % 2", 1.5},
+		{"2 ** 0.5", math.Sqrt2},
+		{"2 ** -1", 0.5},
+		{"1.5 ** 2", 2.25},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 <= 1.5", false},
+		{"1.0 == 1", true},
+		{"1 != 1.5", true},
+		{"2.5 >= 2.5", true},
+		{"-0.5 > -1", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -72,6 +147,30 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let calls = 0; let f = fn() { calls += 1; true }; false && f(); true || f(); calls == 0", true},
+		{"1 && 0", true},
+		{"if (true) { false } || 1", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -104,6 +203,11 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if 1 < 2 { 10 } else { 20 }", 10},
+		{"if 1 > 2 { 10 } else if 2 > 3 { 20 } else { 30 }", 30},
+		{"if 1 > 2 { 10 } else if (2 < 3) { 20 } else { 30 }", 20},
+		{"if 1 > 2 { 10 } else if 2 > 3 { 20 }", nil},
+		{"let x = 3; if (x == 1) { 1 } else if (x == 2) { 2 } else if (x == 3) { 3 }", 3},
 	}
 
 	for _, tt := range tests {
@@ -215,6 +319,46 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			"1 / 0",
+			"division by zero: 1 / 0",
+		},
+		{
+			"5 % 0",
+			"division by zero: 5 % 0",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"true && undefined",
+			"identifier not found: undefined",
+		},
+		{
+			"if (undefined) { 1 } else { 2 }",
+			"identifier not found: undefined",
+		},
+		{
+			`1.5 + "a"`,
+			"type mismatch: FLOAT + STRING",
+		},
+		{
+			"let f = fn(a, b) { a }; f(1)",
+			"wrong number of arguments. got=1, want=2",
+		},
+		{
+			"fn() { 1 }(2)",
+			"wrong number of arguments. got=1, want=0",
+		},
 	}
 
 	for i, tt := range tests {
@@ -250,6 +394,118 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = 10;", 10},
+		{"let a = 5; let b = 1; a = b = 3; a + b;", 6},
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2; a;", 3},
+		{"let a = 5; a *= 2; a;", 10},
+		{"let a = 6; a /= 2; a;", 3},
+		{`let s = "he"; s += "yo"; s;`, "heyo"},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; }; sum;", 6},
+		{"let i = 0; for (i < 5) { i += 1; }; i;", 5},
+		{"let counter = fn() { let n = 0; fn() { n += 1; } }; let c = counter(); c(); c(); c();", 3},
+		{"let arr = [1, 2, 3]; arr[0] = 5; arr[0];", 5},
+		{"let arr = [1, 2, 3]; arr[-1] *= 3; arr[2];", 9},
+		{`let h = {"a": 1}; h["a"] += 1; h["a"];`, 2},
+		{`let h = {}; h["b"] = 2; h["b"];`, 2},
+		{"x = 5;", "assignment to undeclared identifier: x"},
+		{"x += 5;", "assignment to undeclared identifier: x"},
+		{"let arr = [1]; arr[1] = 2;", "array index out of range: 1"},
+		{"let h = {}; h[fn(){}] = 2;", "unusable as hash key: FUNCTION"},
+		{"let a = 5; a += true;", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q",
+						expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q",
+						expected, obj.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestAssignUndeclaredLocation(t *testing.T) {
+	evaluated := testEval("let a = 1;\n  b = 2;")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Location == nil || errObj.Location.Line != 2 || errObj.Location.Column != 5 {
+		t.Errorf("wrong error location. got=%+v", errObj.Location)
+	}
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedLocation token.TokenLocation
+		expectedStack    []string
+	}{
+		{
+			`let divide = fn(a, b) { a / b };
+let half = fn(x) { divide(x, 0) };
+let run = fn() { half(4) };
+run()`,
+			token.TokenLocation{Line: 1, Column: 27},
+			[]string{"divide 2:20", "half 3:18", "run 4:1"},
+		},
+		{"fn() { 1 / 0 }()", token.TokenLocation{Line: 1, Column: 10}, []string{"<anonymous> 1:1"}},
+		{"len(1, 2)", token.TokenLocation{Line: 1, Column: 1}, []string{}},
+		{
+			`let boom = async fn() { -true };
+let wait = fn() { await boom() };
+wait()`,
+			token.TokenLocation{Line: 1, Column: 25},
+			[]string{"boom 2:25", "wait 3:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+
+		loc := errObj.Location
+		if loc == nil || loc.Line != tt.expectedLocation.Line || loc.Column != tt.expectedLocation.Column {
+			t.Errorf("wrong error location. want=%+v, got=%+v", tt.expectedLocation, errObj.Location)
+		}
+
+		stack := []string{}
+		for _, frame := range errObj.Stack {
+			stack = append(stack, fmt.Sprintf("%s %d:%d", frame.Function, frame.Location.Line, frame.Location.Column))
+		}
+		if strings.Join(stack, ", ") != strings.Join(tt.expectedStack, ", ") {
+			t.Errorf("wrong stack. want=%v, got=%v", tt.expectedStack, stack)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -299,7 +555,224 @@ func TestAsyncFunction(t *testing.T) {
 
 	evaluated := testEval(input)
 
-	testNullObject(t, evaluated)
+	future, ok := evaluated.(*object.Future)
+	if !ok {
+		t.Fatalf("object is not Future. got=%T (%+v)", evaluated, evaluated)
+	}
+	testIntegerObject(t, future.Wait(), 5)
+}
+
+func TestAwaitExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"await async fn(x) { x * 2 }(5)", 10},
+		{"let f = async fn(x) { return x + 1; 0 }; await f(1)", 2},
+		{"await async fn() { }()", nil},
+		{"await 5", 5},
+		{"let a = async fn() { 1 }; let b = async fn() { 2 }; let fa = a(); let fb = b(); await fa + await fb", 3},
+		{"await all([async fn() { 1 }(), 2, async fn() { 3 }()])", []int64{1, 2, 3}},
+		{"await all([])", []int64{}},
+		{"await race([async fn() { 7 }()])", 7},
+		{"await race([5, async fn() { for (i in range(100000)) {}; 6 }()])", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(arr.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, arr.Elements[i], el)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// TestAsyncSharedEnvironment is meant to be run with `go test -race`
+func TestAsyncSharedEnvironment(t *testing.T) {
+	input := `
+		let shared = 0;
+		let futures = [];
+		for (i in range(200)) {
+			futures.push(async fn(x) {
+				let doubled = x * 2;
+				shared = x;
+				let seen = shared;
+				doubled
+			}(i));
+			let unrelated = i;
+		}
+		let sum = 0;
+		for (v in await all(futures)) { sum += v }
+		sum
+	`
+
+	testIntegerObject(t, testEval(input), 39800)
+}
+
+// TestAsyncSharedValues changes arrays, hashes and bindings from many async functions at once.
+// No update may be lost, and `go test -race` must not report a race.
+func TestAsyncSharedValues(t *testing.T) {
+	input := `
+		let pushed = [];
+		let slots = [];
+		for (i in range(2000)) { slots.push(0) }
+		let buckets = [0, 0, 0, 0];
+		let keyed = {};
+		let counter = {"n": 0};
+		let total = 0;
+
+		let futures = [];
+		for (i in range(2000)) {
+			futures.push(async fn(x) {
+				pushed.push(x);
+				slots[x] = x;
+				buckets[x % 4] += 1;
+				keyed[x] = x;
+				counter.n += 1;
+				total += 1;
+			}(i));
+		}
+		await all(futures);
+
+		let sum = 0;
+		for (v in slots) { sum += v };
+		[pushed.len(), sum, buckets[0], buckets[3], keyed.len(), counter.n, total]
+	`
+
+	evaluated := testEval(input)
+	arr, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	expected := []int64{2000, 1999000, 500, 500, 2000, 2000, 2000}
+	if len(arr.Elements) != len(expected) {
+		t.Fatalf("wrong num of elements. want=%d, got=%d", len(expected), len(arr.Elements))
+	}
+	for i, el := range expected {
+		testIntegerObject(t, arr.Elements[i], el)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let ch = channel(); async fn() { ch.send(5) }(); ch.recv()", 5},
+		{"let ch = channel(2); ch.send(1); ch.send(2); ch.len() + ch.recv() * 10 + ch.recv() * 100", 212},
+		{"let ch = channel(1); ch.send(1); ch.close(); ch.recv() + 1", 2},
+		{"let ch = channel(); ch.close(); ch.recv()", nil},
+		{`
+			let ch = channel();
+			let producer = async fn(n) {
+				for (i in range(n)) { ch.send(i) }
+				ch.close();
+			};
+			producer(10);
+			let sum = 0;
+			for (true) {
+				let v = ch.recv();
+				if (!v) { break }
+				sum += v;
+			}
+			sum
+		`, 45},
+		{`
+			let results = channel();
+			let work = async fn(x) { results.send(x * x) };
+			for (i in range(1, 4)) { work(i) }
+			results.recv() + results.recv() + results.recv()
+		`, 14},
+		{"let ch = channel(); select { case v = ch.recv() { v } default { 7 } }", 7},
+		{"let ch = channel(1); ch.send(3); select { case v = ch.recv() { v * 2 } default { 7 } }", 6},
+		{"let ch = channel(1); select { case ch.send(4) { ch.recv() } }", 4},
+		{`
+			let a = channel();
+			let b = channel();
+			async fn() { b.send(9) }();
+			select { case x = a.recv() { x } case y = b.recv() { y + 1 } }
+		`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestChannelErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let ch = channel(); ch.recv()", "deadlock, all tasks are blocked: recv on channel#"},
+		{"let ch = channel(); ch.send(1)", "deadlock, all tasks are blocked: send on channel#"},
+		{"let ch = channel(); await async fn() { ch.recv() }()", "deadlock, all tasks are blocked: await future, recv on channel#"},
+		{"select {}", "deadlock, all tasks are blocked: empty select"},
+		{"let ch = channel(1); ch.close(); ch.send(1)", "send on closed channel#"},
+		{"let ch = channel(); ch.close(); ch.close()", "close of closed channel#"},
+		{"channel(-1)", "`channel` capacity must not be negative, got=-1"},
+		{"select { case v = 1.recv() { v } }", "select case needs a CHANNEL, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if !strings.HasPrefix(errObj.Message, tt.expectedMessage) {
+			t.Errorf("wrong error message. expected prefix=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Location == nil {
+			t.Errorf("error for %q has no location", tt.input)
+		}
+	}
+}
+
+func TestAwaitErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"await async fn() { 1 / 0 }()", "division by zero: 1 / 0"},
+		{"await async fn() { let x = -true; x }()", "unknown operator: -BOOLEAN"},
+		{"await all([async fn() { 1 }(), async fn() { unknown }()])", "identifier not found: unknown"},
+		{"all(1)", "argument to `all` must be ARRAY, got=INTEGER"},
+		{"race([])", "`race` needs at least one future"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
 }
 
 func TestEnclosingEnvironments(t *testing.T) {
@@ -345,6 +818,64 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"say \"hi\""`, `say "hi"`},
+		{`"a\tb" + "\n"`, "a\tb\n"},
+		{"`C:\\path\\n`", `C:\path\n`},
+		{`"\u{1F600}"`, "😀"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = 1; let b = 2; "total: ${a + b}"`, "total: 3"},
+		{`"${1.5} ${true} ${[1, 2]}"`, "1.5 true [1, 2]"},
+		{`let name = "donkey"; "hi ${name}, ${"nested ${name}"}!"`, "hi donkey, nested donkey!"},
+		{`let h = {"k": "v"}; "${h["k"]}"`, "v"},
+		{`"price: \${no}"`, "price: ${no}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"broken ${1 + true}"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -636,6 +1167,151 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"for (x in [1, 2, 3]) { x; }", nil},
+		{"for (false) { 1; }", nil},
+		{"for (true) { break; }", nil},
+		{"let find = fn(arr, y) { for (i, x in arr) { if (x == y) { return i; } }; -1; }; find([4, 5, 6], 6);", 2},
+		{"let find = fn(arr, y) { for (i, x in arr) { if (x == y) { return i; } }; -1; }; find([4, 5, 6], 7);", -1},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x < 3) { continue; } return x; } }; f();", 3},
+		{"let f = fn() { for (x in [1, 2, 3]) { break; return x; } 42; }; f();", 42},
+		{`let f = fn() { for (i, c in "héllo") { if (c == "l") { return i; } } }; f();`, 2},
+		{`let f = fn() { for (c in "héllo") { if (c != "h") { return c; } } }; f();`, "é"},
+		{`let f = fn() { for (k, v in {"a": 1, "b": 2}) { if (v == 2) { return k; } } }; f();`, "b"},
+		{`let f = fn() { for (k in {"b": 1, "a": 2}) { return k; } }; f();`, "a"},
+		{"let f = fn() { for (n in range(10, 0, -3)) { if (n < 5) { return n; } } }; f();", 4},
+		{"let f = fn() { for (i, n in range(5)) { if (i == 3) { return n; } } }; f();", 3},
+		{"let f = fn() { for (x in [1, 2]) { for (y in [3, 4]) { break; } return x; } }; f();", 1},
+		{"len(range(0, 10, 3))", 4},
+		{"len(range(10, 0, -1))", 10},
+		{"len(range(5, 0))", 0},
+		{"len(range(9223372036854775800, 9223372036854775807, 5))", 2},
+		{"len(range(-9223372036854775807, 9223372036854775807))", 9223372036854775807},
+		{"let c = 0; for (n in range(9223372036854775800, 9223372036854775807, 5)) { c += 1 }; c", 2},
+		{"let c = 0; for (n in range(-9223372036854775800, -9223372036854775807, -5)) { c += 1 }; c", 2},
+		{"let z = 0; for (x in range(3)) { let y = if (x == 1) { break; } else { x }; z = y + 1 }; z", 1},
+		{"let s = 0; for (x in range(4)) { let y = if (x % 2 == 0) { continue; } else { x }; s += y }; s", 4},
+		{"let f = fn() { let y = if (true) { return 7; } else { 1 }; 0 }; f();", 7},
+		{"let f = fn(x) { 99 }; let n = 0; for (x in range(3)) { n += 1; f(if (true) { break; }) }; n", 1},
+		{"let n = 0; for (x in range(3)) { n += 1; 1 + if (true) { break; } else { 0 }; n += 10 }; n", 1},
+		{"let n = 0; for (x in range(3)) { n += 1; -if (x > 0) { continue; } else { 0 }; n += 10 }; n", 13},
+		{"let n = 0; for (x in range(3)) { [1, if (x > 0) { continue; } else { 0 }]; n += 1 }; n", 1},
+		{`let n = 0; for (x in range(3)) { {"a": if (x > 0) { continue; } else { 0 }}; n += 1 }; n`, 1},
+		{"let n = 0; for (x in range(3)) { n = if (x == 2) { break; } else { x + 10 } }; n", 11},
+		{"let f = fn(x) { x }; let g = fn() { f(if (true) { return 7; } else { 1 }); 0 }; g();", 7},
+		{"for (x in 5) { x; }", "for-in not supported for: INTEGER"},
+		{"for (x in [1]) { x + true; }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (x in [1]) { x; }; x", "identifier not found: x"},
+		{"range(0, 10, 0)", "`range` step must not be 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q",
+						expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q",
+						expected, obj.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {"name": "donkey"}; h.name`, "donkey"},
+		{`let h = {"a": {"b": 2}}; h.a.b`, 2},
+		{`let h = {}; h.missing`, nil},
+		{`let h = {"a": 1}; h.a = 5; h.a += 1; h["a"]`, 6},
+		{`let h = {"len": 42}; h.len`, 42},
+		{`let h = {"a": 1, "b": 2}; h.len()`, 2},
+		{`{"b": 1, "a": 2}.keys().join(",")`, "a,b"},
+		{`{"b": 1, "a": 2}.values()[0]`, 2},
+		{`{"a": 1}.has("a")`, true},
+		{`{"a": 1}.has("b")`, false},
+		{`"abc".upper()`, "ABC"},
+		{`"ABC".lower()`, "abc"},
+		{`"  x ".trim()`, "x"},
+		{`"abc".len()`, 3},
+		{`"a,b,c".split(",").len()`, 3},
+		{`"donkey".contains("key")`, true},
+		{`let arr = [1, 2, 3]; arr.push(4); arr.len()`, 4},
+		{`let arr = [1, 2, 3]; arr.push(4).push(5).last()`, 5},
+		{`let arr = [1, 2, 3]; arr.push(4, 5)`, "wrong number of arguments to `push`. got=2, want=1"},
+		{`[].push()`, "wrong number of arguments to `push`. got=0, want=1"},
+		{`let arr = [1]; let copy = push(arr, 2); arr.len() * 10 + copy.len()`, 12},
+		{`let arr = [1]; let same = arr.push(2); arr.len() * 10 + same.len()`, 22},
+		{`let arr = [1, 2, 3]; arr.pop() + arr.len()`, 5},
+		{`[].pop()`, nil},
+		{`[1, 2].first()`, 1},
+		{`[1, 2, 3].join("-")`, "1-2-3"},
+		{`let upper = "abc".upper; upper()`, "ABC"},
+		{`5.foo`, "unknown member foo on INTEGER"},
+		{`"abc".foo()`, "unknown member foo on STRING"},
+		{`"abc".split(1)`, "argument to `split` must be STRING, got=INTEGER"},
+		{`let x = 5; x.a = 1`, "member assignment not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q",
+						expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q",
+						expected, obj.Value)
+				}
+			default:
+				t.Errorf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestRegisterMethod(t *testing.T) {
+	RegisterMethod(object.INTEGER_OBJ, "double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	defer delete(methods, object.INTEGER_OBJ)
+
+	testIntegerObject(t, testEval("let x = 21; x.double()"), 42)
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -659,6 +1335,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if math.Abs(result.Value-expected) > 1e-9 {
+		t.Errorf("object has wrong value. got=%f, want=%f",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -25,8 +25,16 @@ func DefineMacros(program *ast.Program, env *object.Environment) {
 	}
 }
 
-func ExpandMacros(program ast.Node, env *object.Environment) ast.Node {
-	return ast.Modify(program, func(node ast.Node) ast.Node {
+// ExpandMacros replaces all macro calls with the AST the macros return.
+// It stops at the first macro that fails or does not return a quote and returns that error.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var expandErr *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if expandErr != nil {
+			return node
+		}
+
 		callExpression, ok := node.(*ast.CallExpression)
 		if !ok {
 			return node
@@ -40,14 +48,24 @@ func ExpandMacros(program ast.Node, env *object.Environment) ast.Node {
 		args := quoteArgs(callExpression)
 		evalEnv := extendMacroEnv(macro, args)
 
+		name := callExpression.Function.(*ast.Identifier)
 		evaluated := Eval(macro.Body, evalEnv)
+		if err, ok := evaluated.(*object.Error); ok {
+			expandErr = err
+			expandErr.Stack = append(expandErr.Stack, object.Frame{Function: name.Value, Location: &name.Token.Location})
+			return node
+		}
+
 		quote, ok := evaluated.(*object.Quote)
 		if !ok {
-			panic("Donkey only supports returning AST-nodes from macros")
+			expandErr = newError("macro %s must return a quote, got=%s", &name.Token.Location, name.Value, evaluated.Type())
+			return node
 		}
 
 		return quote.Node
 	})
+
+	return expanded, expandErr
 }
 
 func isMacroCall(
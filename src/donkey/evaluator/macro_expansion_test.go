@@ -106,7 +106,10 @@ unless(10 > 5, print("not greater"), print("greater"));
 
 		env := object.NewEnvironment()
 		DefineMacros(program, env)
-		expanded := ExpandMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("macro expansion failed: %s", err.Message)
+		}
 
 		if expanded.String() != expected.String() {
 			t.Errorf("not equal. want=%q, got=%q",
@@ -120,3 +123,41 @@ func testParseProgram(input string) *ast.Program {
 	p := parser.New(l)
 	return p.ParseProgram()
 }
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		line     int
+		column   int
+	}{
+		{
+			"let number = macro() { 1 };\nnumber();",
+			"macro number must return a quote, got=INTEGER",
+			2, 1,
+		},
+		{
+			"let broken = macro() { missing };\nbroken();",
+			"identifier not found: missing",
+			1, 24,
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Fatalf("expected an error for %q", tt.input)
+		}
+
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message. want=%q, got=%q", tt.expected, err.Message)
+		}
+		if err.Location == nil || err.Location.Line != tt.line || err.Location.Column != tt.column {
+			t.Errorf("wrong error location. want=%d:%d, got=%v", tt.line, tt.column, err.Location)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package evaluator
+
+import (
+	"donkey/object"
+	"strings"
+)
+
+// methods maps an object type to the builtins reachable via `value.name()`.
+// A method receives its receiver as the first argument, so plain builtins like `len` can be reused.
+var methods = map[object.ObjectType]map[string]*object.Builtin{
+	object.STRING_OBJ: {
+		"len":      builtins["len"],
+		"upper":    stringMethod("upper", strings.ToUpper),
+		"lower":    stringMethod("lower", strings.ToLower),
+		"trim":     stringMethod("trim", strings.TrimSpace),
+		"split":    methodSplit(),
+		"contains": methodContains(),
+	},
+	object.ARRAY_OBJ: {
+		"len":   builtins["len"],
+		"first": builtins["first"],
+		"last":  builtins["last"],
+		"rest":  builtins["rest"],
+		"push":  methodPush(),
+		"pop":   methodPop(),
+		"join":  methodJoin(),
+	},
+	object.HASH_OBJ: {
+		"len":    methodHashLen(),
+		"keys":   methodKeys(),
+		"values": methodValues(),
+		"has":    methodHas(),
+	},
+	object.CHANNEL_OBJ: {
+		"len":   methodChannelLen(),
+		"send":  methodSend(),
+		"recv":  methodRecv(),
+		"close": methodClose(),
+	},
+	object.TIMER_OBJ: {
+		"cancel": methodCancel(),
+	},
+}
+
+// RegisterMethod adds or replaces the method `name` for all values of type t.
+// It is meant to be called by embedders during setup, before any code is evaluated.
+func RegisterMethod(t object.ObjectType, name string, fn object.BuiltinFunction) {
+	if methods[t] == nil {
+		methods[t] = map[string]*object.Builtin{}
+	}
+	methods[t][name] = &object.Builtin{Fn: fn}
+}
+
+// lookupMethod returns the method bound to the receiver, so it can be called like a builtin
+func lookupMethod(receiver object.Object, name string) (*object.Builtin, bool) {
+	method, ok := methods[receiver.Type()][name]
+	if !ok {
+		return nil, false
+	}
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return method.Fn(append([]object.Object{receiver}, args...)...)
+		},
+	}, true
+}
+
+func stringMethod(name string, fn func(string) string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `%s`. got=%d, want=0", nil, name, len(args)-1)
+			}
+			return &object.String{Value: fn(args[0].(*object.String).Value)}
+		},
+	}
+}
+
+func methodSplit() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `split`. got=%d, want=1", nil, len(args)-1)
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `split` must be STRING, got=%s", nil, args[1].Type())
+			}
+
+			parts := strings.Split(args[0].(*object.String).Value, sep.Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		},
+	}
+}
+
+func methodContains() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `contains`. got=%d, want=1", nil, len(args)-1)
+			}
+			sub, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `contains` must be STRING, got=%s", nil, args[1].Type())
+			}
+			return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, sub.Value))
+		},
+	}
+}
+
+// methodPush appends to the array in place, unlike the `push` builtin which returns a copy
+func methodPush() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `push`. got=%d, want=1", nil, len(args)-1)
+			}
+
+			arr := args[0].(*object.Array)
+			arr.Push(args[1])
+			return arr
+		},
+	}
+}
+
+// methodPop removes the last element in place and returns it
+func methodPop() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `pop`. got=%d, want=0", nil, len(args)-1)
+			}
+
+			last, ok := args[0].(*object.Array).Pop()
+			if !ok {
+				return NULL
+			}
+			return last
+		},
+	}
+}
+
+func methodJoin() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `join`. got=%d, want=1", nil, len(args)-1)
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `join` must be STRING, got=%s", nil, args[1].Type())
+			}
+
+			elements := args[0].(*object.Array).Snapshot()
+			parts := make([]string, len(elements))
+			for i, el := range elements {
+				parts[i] = el.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	}
+}
+
+func methodHashLen() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(args[0].(*object.Hash).Len())}
+		},
+	}
+}
+
+func methodKeys() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			pairs := sortedHashPairs(args[0].(*object.Hash))
+			keys := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				keys[i] = pair.Key
+			}
+			return &object.Array{Elements: keys}
+		},
+	}
+}
+
+func methodValues() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			pairs := sortedHashPairs(args[0].(*object.Hash))
+			values := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.Value
+			}
+			return &object.Array{Elements: values}
+		},
+	}
+}
+
+func methodHas() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `has`. got=%d, want=1", nil, len(args)-1)
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", nil, args[1].Type())
+			}
+			_, ok = args[0].(*object.Hash).Get(key.HashKey())
+			return nativeBoolToBooleanObject(ok)
+		},
+	}
+}
+
+func methodChannelLen() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(args[0].(*object.Channel).Len())}
+		},
+	}
+}
+
+// methodSend blocks until the value is received or buffered
+func methodSend() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `send`. got=%d, want=1", nil, len(args)-1)
+			}
+			if res := args[0].(*object.Channel).Send(args[1]); res != nil {
+				return res
+			}
+			return NULL
+		},
+	}
+}
+
+// methodRecv blocks until a value is sent, or returns null once the channel is closed and drained
+func methodRecv() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `recv`. got=%d, want=0", nil, len(args)-1)
+			}
+			if res := args[0].(*object.Channel).Recv(); res != nil {
+				return res
+			}
+			return NULL
+		},
+	}
+}
+
+func methodClose() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `close`. got=%d, want=0", nil, len(args)-1)
+			}
+			if err := args[0].(*object.Channel).Close(); err != nil {
+				return err
+			}
+			return NULL
+		},
+	}
+}
+
+// methodCancel stops a timer, it returns false if the timer already fired or was cancelled before
+func methodCancel() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments to `cancel`. got=%d, want=0", nil, len(args)-1)
+			}
+			return nativeBoolToBooleanObject(args[0].(*object.Timer).Cancel())
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package evaluator
+
+import (
+	"donkey/ast"
+	"donkey/lexer"
+	"donkey/object"
+	"donkey/parser"
+	"donkey/token"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// modules caches every imported module by its absolute path, so each file is evaluated only once.
+// Async tasks importing the same module at the same time may both evaluate it, the first one is kept.
+var modules = struct {
+	mu     sync.Mutex
+	loaded map[string]*object.Module
+}{loaded: map[string]*object.Module{}}
+
+func evalImportExpression(ie *ast.ImportExpression, env *object.Environment) object.Object {
+	loc := &ie.Token.Location
+
+	path := resolveImportPath(ie.Path.Value, env.File())
+
+	name := moduleName(path)
+	if ie.Alias != nil {
+		name = ie.Alias.Value
+	} else if !isIdentifier(name) {
+		return newError("can not derive a module name from %q, use `import %q as name`", loc, ie.Path.Value, ie.Path.Value)
+	}
+
+	module := loadModule(path, env.ImportChain(), loc)
+	if isError(module) {
+		return module
+	}
+
+	env.Set(name, module)
+	return module
+}
+
+// resolveImportPath makes the path absolute, relative to the importing file or the working directory
+func resolveImportPath(path string, importer string) string {
+	if !filepath.IsAbs(path) && importer != "" {
+		path = filepath.Join(filepath.Dir(importer), path)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// loadModule evaluates the module at path, or returns it from the cache.
+// The chain holds the files whose imports led here, it ends with the importing file.
+func loadModule(path string, chain []string, loc *token.TokenLocation) object.Object {
+	modules.mu.Lock()
+	module, ok := modules.loaded[path]
+	modules.mu.Unlock()
+	if ok {
+		return module
+	}
+
+	for i, loading := range chain {
+		if loading == path {
+			chain := append(append([]string{}, chain[i:]...), path)
+			for j := range chain {
+				chain[j] = filepath.Base(chain[j])
+			}
+			return newError("import cycle: %s", loc, strings.Join(chain, " -> "))
+		}
+	}
+
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return newError("can not import %s: %s", loc, path, err)
+	}
+
+	l := lexer.New(string(input), lexer.WithFile(path))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		// the parse errors are located in the module, so they can be shown with its code
+		moduleErr := newError("parse errors in module %s", loc, path)
+		for _, err := range p.Errors() {
+			start := err.Start
+			moduleErr.Causes = append(moduleErr.Causes, &object.Error{Message: err.Message, Location: &start})
+		}
+		return moduleErr
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	node, expandErr := ExpandMacros(program, macroEnv)
+	if expandErr != nil {
+		return moduleError(path, expandErr, loc)
+	}
+	expanded := node.(*ast.Program)
+
+	env := object.NewModuleEnvironment(path, chain)
+	evaluated := Eval(expanded, env)
+	if err, ok := evaluated.(*object.Error); ok {
+		return moduleError(path, err, loc)
+	}
+
+	module = &object.Module{Name: moduleName(path), Path: path, Exports: map[string]object.Object{}}
+	for _, stmt := range expanded.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Exported {
+			module.Exports[let.Name.Value], _ = env.Get(let.Name.Value)
+		}
+	}
+
+	modules.mu.Lock()
+	defer modules.mu.Unlock()
+	if loaded, ok := modules.loaded[path]; ok {
+		return loaded
+	}
+	modules.loaded[path] = module
+	return module
+}
+
+// moduleError reports an error of the imported module at the import
+func moduleError(path string, err *object.Error, loc *token.TokenLocation) *object.Error {
+	where := path
+	if err.Location != nil {
+		where = fmt.Sprintf("%s:%d:%d", path, err.Location.Line, err.Location.Column)
+	}
+	return newError("in module %s: %s", loc, where, err.Message)
+}
+
+// moduleName is the file name without its extension, e.g. `lib` for `path/to/lib.dk`
+func moduleName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// isIdentifier reports whether the name lexes as a single identifier, so it can be bound and referenced
+func isIdentifier(name string) bool {
+	tok := lexer.New(name).NextToken()
+	return tok.Type == token.IDENT && tok.Literal == name
+}
+
+func evalModuleMember(module *object.Module, name string, loc *token.TokenLocation) object.Object {
+	if val, ok := module.Exports[name]; ok {
+		return val
+	}
+	return newError("module %s has no export %s", loc, module.Name, name)
+}
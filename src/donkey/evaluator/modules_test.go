@@ -0,0 +1,166 @@
+package evaluator
+
+import (
+	"donkey/lexer"
+	"donkey/object"
+	"donkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModules creates the given files in a fresh directory and returns its path
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalFile(t *testing.T, path string) object.Object {
+	t.Helper()
+	input, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := parser.New(lexer.New(string(input)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Eval(program, object.NewFileEnvironment(path))
+}
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math.dk": `
+			let square = fn(x) { x * x };
+			export let double = fn(x) { add(x, x) };
+			export let add = fn(a, b) { a + b };
+			export let pi = 3;
+		`,
+		"main.dk": `
+			import "lib/math.dk";
+			import "lib/math.dk" as m;
+			math.double(2) + m["add"](1, 2) + m.pi
+		`,
+		"private.dk": `
+			import "lib/math.dk";
+			math.square(2)
+		`,
+		"cached.dk": `
+			let a = import "lib/math.dk";
+			let b = import "./lib/../lib/math.dk";
+			a == b
+		`,
+	})
+
+	testIntegerObject(t, testEvalFile(t, filepath.Join(dir, "main.dk")), 10)
+	testBooleanObject(t, testEvalFile(t, filepath.Join(dir, "cached.dk")), true)
+
+	err, ok := testEvalFile(t, filepath.Join(dir, "private.dk")).(*object.Error)
+	if !ok {
+		t.Fatalf("expected error for unexported binding")
+	}
+	if err.Message != "module math has no export square" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+}
+
+func TestImportRelativeToImportingFile(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a/b/c.dk": `export let value = 42;`,
+		"a/b.dk":   `import "b/c.dk"; export let value = c.value;`,
+		"main.dk":  `import "a/b.dk"; b.value`,
+	})
+
+	testIntegerObject(t, testEvalFile(t, filepath.Join(dir, "main.dk")), 42)
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.dk":       `import "b.dk"; export let a = 1;`,
+		"b.dk":       `import "a.dk"; export let b = 1;`,
+		"cycle.dk":   `import "a.dk"`,
+		"missing.dk": `import "nope.dk"`,
+		"broken.dk":  `let = 1;`,
+		"parse.dk":   `import "broken.dk"`,
+		"failing.dk": `let x = 1 / 0;`,
+		"runtime.dk": `import "failing.dk"`,
+		"my-lib.dk":  `export let x = 1;`,
+		"badname.dk": `import "my-lib.dk"`,
+		"aliased.dk": `import "my-lib.dk" as lib; lib.x`,
+	})
+
+	tests := []struct {
+		file            string
+		expectedMessage string
+	}{
+		{"cycle.dk", "import cycle: a.dk -> b.dk -> a.dk"},
+		{"missing.dk", "can not import " + filepath.Join(dir, "nope.dk")},
+		{"parse.dk", "parse errors in module " + filepath.Join(dir, "broken.dk")},
+		{"runtime.dk", "in module " + filepath.Join(dir, "failing.dk") + ":1:11: division by zero: 1 / 0"},
+		{"badname.dk", "can not derive a module name from \"my-lib.dk\""},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEvalFile(t, filepath.Join(dir, tt.file)).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error", tt.file)
+			continue
+		}
+		if !strings.Contains(err.Message, tt.expectedMessage) {
+			t.Errorf("%s: wrong error message. want=%q, got=%q", tt.file, tt.expectedMessage, err.Message)
+		}
+	}
+
+	// parse errors are located in the module, so they can be shown with its code
+	err, ok := testEvalFile(t, filepath.Join(dir, "parse.dk")).(*object.Error)
+	if !ok || len(err.Causes) != 1 {
+		t.Fatalf("parse.dk: expected error with one cause. got=%+v", err)
+	}
+	if loc := err.Causes[0].Location; loc == nil || loc.File != filepath.Join(dir, "broken.dk") || loc.Line != 1 || loc.Column != 5 {
+		t.Errorf("parse.dk: wrong location of the parse error. got=%+v", loc)
+	}
+
+	testIntegerObject(t, testEvalFile(t, filepath.Join(dir, "aliased.dk")), 1)
+
+	// the entry file is part of the chain, it is not evaluated a second time
+	err, ok = testEvalFile(t, filepath.Join(dir, "a.dk")).(*object.Error)
+	if !ok {
+		t.Fatalf("a.dk: expected error")
+	}
+	expected := "in module " + filepath.Join(dir, "b.dk") + ":1:1: import cycle: a.dk -> b.dk -> a.dk"
+	if err.Message != expected {
+		t.Errorf("a.dk: wrong error message. want=%q, got=%q", expected, err.Message)
+	}
+}
+
+func TestParallelImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib.dk": `export let value = 42;`,
+		"main.dk": `
+			let futures = [];
+			for (i in range(20)) { futures.push(async fn() { import "lib.dk"; lib.value }()) }
+			await all(futures)
+		`,
+	})
+
+	result, ok := testEvalFile(t, filepath.Join(dir, "main.dk")).(*object.Array)
+	if !ok || len(result.Elements) != 20 {
+		t.Fatalf("expected an array of 20 values. got=%v", result)
+	}
+	for _, value := range result.Elements {
+		testIntegerObject(t, value, 42)
+	}
+}
@@ -0,0 +1,186 @@
+package evaluator
+
+import (
+	"donkey/object"
+	"donkey/token"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AsyncError is an error an async function failed with, while nobody awaited its future
+type AsyncError struct {
+	Function string // name of the async function or `<anonymous>`
+	Location *token.TokenLocation
+	Err      *object.Error
+}
+
+func (e *AsyncError) Error() string {
+	if e.Location == nil {
+		return fmt.Sprintf("unhandled error in async function %s: %s", e.Function, e.Err.Message)
+	}
+	return fmt.Sprintf("unhandled error in async function %s at line %d, col %d: %s",
+		e.Function, e.Location.Line, e.Location.Column, e.Err.Message)
+}
+
+type UnhandledErrorHandler func(err *AsyncError)
+
+// tasks is the group of all async tasks started by the interpreter
+var tasks = struct {
+	mu      sync.Mutex
+	running int
+	idle    []chan struct{} // closed once no task is running anymore
+	failed  []*failedTask
+	handler UnhandledErrorHandler
+}{handler: StderrErrorHandler(os.Stderr)}
+
+type failedTask struct {
+	future *object.Future
+	err    *AsyncError
+}
+
+// StderrErrorHandler is the default handler, it writes every unhandled error as a line to w
+func StderrErrorHandler(w io.Writer) UnhandledErrorHandler {
+	return func(err *AsyncError) {
+		fmt.Fprintln(w, err.Error())
+	}
+}
+
+// SetUnhandledErrorHandler replaces the handler for errors of async functions that were never awaited
+func SetUnhandledErrorHandler(handler UnhandledErrorHandler) {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+
+	tasks.handler = handler
+}
+
+// UseGoroutineScheduler runs every async function call on its own goroutine, this is the default
+func UseGoroutineScheduler() {
+	object.SetScheduler(object.NewGoroutineScheduler())
+}
+
+// UseDeterministicScheduler runs async function calls one at a time, switching between them
+// at every statement in an order derived from the seed. The same seed reproduces the same interleaving.
+// It must be called from the goroutine that evaluates the program, while no async tasks are running.
+func UseDeterministicScheduler(seed int64) {
+	object.SetScheduler(object.NewDeterministicScheduler(seed))
+}
+
+// spawnTask runs the async function body as a new task and returns the future of its result
+func spawnTask(fn *object.Function, env *object.Environment, loc *token.TokenLocation) *object.Future {
+	future := object.NewFuture()
+
+	startTask()
+	object.Spawn(func() {
+		res := evalTask(fn, env)
+		if res == nil {
+			res = NULL
+		}
+		if err, ok := res.(*object.Error); ok {
+			err.Stack = append(err.Stack, object.Frame{Function: functionName(fn), Location: loc})
+		}
+		// resolve first, a finished task nobody waits for could otherwise look like a deadlock
+		future.Resolve(res)
+
+		var failed *failedTask
+		if err, ok := res.(*object.Error); ok {
+			failed = &failedTask{future: future, err: newAsyncError(fn, err, loc)}
+		}
+		finishTask(failed)
+	})
+	return future
+}
+
+// evalTask evaluates the body of an async function. A panic of the interpreter becomes the error
+// of the task, it is reported like any other instead of crashing the program from another goroutine.
+func evalTask(fn *object.Function, env *object.Environment) (res object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = &object.Error{Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+	return unwrapReturnValue(evalBlockStatement(fn.Body, env))
+}
+
+// startTask registers work that keeps the program alive, a running async function or a pending timer
+func startTask() {
+	tasks.mu.Lock()
+	tasks.running++
+	tasks.mu.Unlock()
+	object.TaskStarted()
+}
+
+// finishTask unregisters work registered with startTask, failed is nil unless an async function returned an error
+func finishTask(failed *failedTask) {
+	tasks.mu.Lock()
+	tasks.running--
+	if failed != nil {
+		tasks.failed = append(tasks.failed, failed)
+	}
+	if tasks.running == 0 {
+		for _, idle := range tasks.idle {
+			close(idle)
+		}
+		tasks.idle = nil
+	}
+	tasks.mu.Unlock()
+	object.TaskDone()
+}
+
+func newAsyncError(fn *object.Function, err *object.Error, callLoc *token.TokenLocation) *AsyncError {
+	loc := err.Location
+	if loc == nil {
+		loc = callLoc
+	}
+	return &AsyncError{Function: functionName(fn), Location: loc, Err: err}
+}
+
+// ReportUnhandledErrors passes errors of finished async functions, whose futures were never
+// awaited, to the unhandled error handler. Each error is reported at most once.
+func ReportUnhandledErrors() {
+	tasks.mu.Lock()
+	failed := tasks.failed
+	tasks.failed = nil
+	handler := tasks.handler
+	tasks.mu.Unlock()
+
+	for _, task := range failed {
+		if !task.future.Observed() {
+			handler(task.err)
+		}
+	}
+}
+
+// WaitForTasks blocks until all async tasks finished or the timeout elapsed, then reports unhandled errors.
+// It returns the amount of tasks that are still running.
+func WaitForTasks(timeout time.Duration) int {
+	done := make(chan struct{})
+	tasks.mu.Lock()
+	if tasks.running == 0 {
+		close(done)
+	} else {
+		tasks.idle = append(tasks.idle, done)
+	}
+	tasks.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(timeout):
+		}
+		close(finished)
+	}()
+
+	// parking lets a single threaded scheduler run the remaining tasks in the meantime. The program
+	// counts as blocked, so tasks that could only be woken up by it are reported as a deadlock.
+	object.ParkBlocked("end of program", finished)
+
+	ReportUnhandledErrors()
+
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	return tasks.running
+}
@@ -0,0 +1,183 @@
+package evaluator
+
+import (
+	"donkey/object"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectUnhandledErrors installs a handler recording all unhandled async errors,
+// after dropping errors left over by other tests
+func collectUnhandledErrors(t *testing.T) *[]*AsyncError {
+	t.Helper()
+	SetUnhandledErrorHandler(func(err *AsyncError) {})
+	WaitForTasks(time.Second)
+
+	errs := &[]*AsyncError{}
+	SetUnhandledErrorHandler(func(err *AsyncError) { *errs = append(*errs, err) })
+	t.Cleanup(func() { SetUnhandledErrorHandler(func(err *AsyncError) {}) })
+	return errs
+}
+
+func TestUnhandledAsyncErrors(t *testing.T) {
+	errs := collectUnhandledErrors(t)
+
+	testEval(`
+		let boom = async fn() { 1 / 0 };
+		boom();
+		async fn() { missing }();
+		await async fn() { -true }();
+		let awaited = boom();
+		await awaited;
+		1
+	`)
+
+	if running := WaitForTasks(time.Second); running != 0 {
+		t.Fatalf("expected all tasks to finish. running=%d", running)
+	}
+
+	expected := []string{
+		"unhandled error in async function boom at line 2, col 29: division by zero: 1 / 0",
+		"unhandled error in async function <anonymous> at line 4, col 16: identifier not found: missing",
+	}
+	if len(*errs) != len(expected) {
+		t.Fatalf("wrong amount of unhandled errors. want=%d, got=%d (%v)", len(expected), len(*errs), *errs)
+	}
+
+	got := map[string]bool{}
+	for _, err := range *errs {
+		got[err.Error()] = true
+	}
+	for _, msg := range expected {
+		if !got[msg] {
+			t.Errorf("missing unhandled error %q. got=%v", msg, got)
+		}
+	}
+}
+
+func TestWaitForTasksTimeout(t *testing.T) {
+	clock, eval := withManualClock(t)
+
+	eval("let waiting = async fn() { sleep(100); 1 }();")
+	if running := WaitForTasks(10 * time.Millisecond); running != 1 {
+		t.Errorf("expected one sleeping task after timeout. running=%d", running)
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if running := WaitForTasks(time.Second); running != 0 {
+		t.Errorf("expected all tasks to finish. running=%d", running)
+	}
+	testIntegerObject(t, eval("await waiting"), 1)
+}
+
+func TestWaitForTasksDeadlock(t *testing.T) {
+	errs := collectUnhandledErrors(t)
+
+	testEval("let ch = channel(); async fn() { ch.recv() }(); 1")
+
+	start := time.Now()
+	if running := WaitForTasks(5 * time.Second); running != 0 {
+		t.Fatalf("expected the blocked task to finish. running=%d", running)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("deadlock was only resolved by the timeout. took=%s", elapsed)
+	}
+
+	if len(*errs) != 1 || !strings.Contains((*errs)[0].Error(), "deadlock, all tasks are blocked: end of program, recv on channel#") {
+		t.Errorf("expected a deadlock error. got=%v", *errs)
+	}
+}
+
+func withDeterministicScheduler(t *testing.T, seed int64, body func()) {
+	t.Helper()
+	collectUnhandledErrors(t)
+
+	UseDeterministicScheduler(seed)
+	defer UseGoroutineScheduler()
+
+	body()
+	if running := WaitForTasks(time.Second); running != 0 {
+		t.Fatalf("expected all tasks to finish. running=%d", running)
+	}
+}
+
+func TestDeterministicScheduler(t *testing.T) {
+	input := `
+		let log = [];
+		let worker = async fn(name) {
+			for (i in range(4)) { log.push(name + "${i}") }
+		};
+		let a = worker("a");
+		let b = worker("b");
+		let c = worker("c");
+		await all([a, b, c]);
+		log.join(" ")
+	`
+
+	interleavings := map[string]bool{}
+	for seed := int64(1); seed <= 5; seed++ {
+		var first, second object.Object
+		withDeterministicScheduler(t, seed, func() { first = testEval(input) })
+		withDeterministicScheduler(t, seed, func() { second = testEval(input) })
+
+		if first.Inspect() != second.Inspect() {
+			t.Errorf("seed %d produced different interleavings: %q and %q", seed, first.Inspect(), second.Inspect())
+		}
+		interleavings[first.Inspect()] = true
+	}
+
+	if len(interleavings) < 2 {
+		t.Errorf("expected different seeds to produce different interleavings. got=%v", interleavings)
+	}
+}
+
+func TestDeterministicSchedulerChannels(t *testing.T) {
+	withDeterministicScheduler(t, 42, func() {
+		testIntegerObject(t, testEval(`
+			let ch = channel();
+			let producer = async fn(n) {
+				for (i in range(n)) { ch.send(i) }
+				ch.close();
+			};
+			producer(10);
+			let sum = 0;
+			for (true) {
+				let v = ch.recv();
+				if (!v) { break }
+				sum += v;
+			}
+			sum
+		`), 45)
+
+		errObj, ok := testEval("let ch = channel(); await async fn() { ch.recv() }()").(*object.Error)
+		if !ok || !strings.HasPrefix(errObj.Message, "deadlock, all tasks are blocked: await future, recv on channel#") {
+			t.Errorf("expected deadlock error. got=%v", errObj)
+		}
+	})
+}
+
+func TestDeterministicSchedulerSelect(t *testing.T) {
+	// both channels are ready every time, so each select picks one at random
+	input := `
+		let a = channel(20);
+		let b = channel(20);
+		for (i in range(20)) { a.send(i); b.send(i) }
+		let picks = [];
+		for (i in range(20)) {
+			select { case x = a.recv() { picks.push("a") } case y = b.recv() { picks.push("b") } }
+		}
+		picks.join("")
+	`
+
+	var first, second object.Object
+	withDeterministicScheduler(t, 7, func() { first = testEval(input) })
+	withDeterministicScheduler(t, 7, func() { second = testEval(input) })
+
+	if first.Inspect() != second.Inspect() {
+		t.Errorf("seed 7 picked different select cases: %q and %q", first.Inspect(), second.Inspect())
+	}
+	if !strings.Contains(first.Inspect(), "a") || !strings.Contains(first.Inspect(), "b") {
+		t.Errorf("expected both cases to be picked. got=%q", first.Inspect())
+	}
+}
@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"donkey/object"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time for `now`, `sleep` and the timer builtins
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f on its own goroutine once d elapsed. Calling stop before reports true and prevents the call.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+var clock atomic.Value
+
+// SetClock replaces the clock, e.g. with a ManualClock in tests
+func SetClock(c Clock) {
+	clock.Store(&c)
+}
+
+func currentClock() Clock {
+	return *clock.Load().(*Clock)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// ManualClock only moves when advanced, so tests can control time without waiting
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	due time.Time
+	f   func()
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTimer{due: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, pending := range c.timers {
+			if pending == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Pending returns the amount of timers that did not fire yet
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// Advance moves the clock forward and fires all timers that are due, in order.
+// Timers scheduled by fired timers also fire if they are due within d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].due.Before(c.timers[j].due) })
+		if len(c.timers) == 0 || c.timers[0].due.After(target) {
+			break
+		}
+
+		next := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = next.due
+
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+
+	c.now = target
+	c.mu.Unlock()
+}
+
+// timer builtins call back into the evaluator, so they are added in init
+// to avoid an initialization cycle with the builtins map
+func init() {
+	SetClock(realClock{})
+
+	builtins["now"] = builtinNow()
+	builtins["sleep"] = builtinSleep()
+	builtins["after"] = builtinTimer("after", false)
+	builtins["every"] = builtinTimer("every", true)
+}
+
+var timerIDs int64
+
+func durationArg(name string, arg object.Object) (time.Duration, *object.Error) {
+	ms, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, newError("argument to `%s` must be INTEGER, got=%s", nil, name, arg.Type())
+	}
+	if ms.Value < 0 {
+		return 0, newError("`%s` duration must not be negative, got=%d", nil, name, ms.Value)
+	}
+	return time.Duration(ms.Value) * time.Millisecond, nil
+}
+
+// builtinNow returns the milliseconds since the unix epoch
+func builtinNow() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", nil, len(args))
+			}
+			return &object.Integer{Value: currentClock().Now().UnixMilli()}
+		},
+	}
+}
+
+// builtinSleep blocks the calling task for the given milliseconds, other tasks keep running
+func builtinSleep() *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", nil, len(args))
+			}
+			d, err := durationArg("sleep", args[0])
+			if err != nil {
+				return err
+			}
+
+			wake := make(chan struct{})
+			currentClock().AfterFunc(d, func() { close(wake) })
+			object.Park(wake)
+			return NULL
+		},
+	}
+}
+
+// builtinTimer creates `after(ms, fn)`, which calls fn once, and `every(ms, fn)`, which calls it repeatedly.
+// fn runs as an async function and may take the timer as its argument, e.g. to cancel it.
+// A pending timer keeps the program alive like a running async function.
+func builtinTimer(name string, repeat bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", nil, len(args))
+			}
+			d, err := durationArg(name, args[0])
+			if err != nil {
+				return err
+			}
+			fn, ok := args[1].(*object.Function)
+			if !ok {
+				return newError("second argument to `%s` must be FUNCTION, got=%s", nil, name, args[1].Type())
+			}
+			if len(fn.Parameters) > 1 {
+				return newError("function passed to `%s` must take at most 1 argument, got=%d", nil, name, len(fn.Parameters))
+			}
+			if repeat && d == 0 {
+				return newError("`every` interval must be greater than 0", nil)
+			}
+
+			return startTimer(d, repeat, fn)
+		},
+	}
+}
+
+func startTimer(d time.Duration, repeat bool, fn *object.Function) *object.Timer {
+	var mu sync.Mutex
+	var stop func() bool
+	stopped := false
+
+	timer := &object.Timer{ID: atomic.AddInt64(&timerIDs, 1), Interval: d, Repeat: repeat}
+	args := []object.Object{timer}[:len(fn.Parameters)]
+
+	var fire func()
+	fire = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+
+		// the arguments always fit, the builtins check that fn takes at most one
+		env, _ := extendFunctionEnv(fn, args, nil)
+		spawnTask(fn, env, nil)
+		if repeat {
+			stop = currentClock().AfterFunc(d, fire)
+			return
+		}
+		stopped = true
+		finishTask(nil)
+	}
+
+	timer.Cancel = func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return false
+		}
+
+		stopped = true
+		stop()
+		finishTask(nil)
+		return true
+	}
+
+	startTask()
+	mu.Lock()
+	stop = currentClock().AfterFunc(d, fire)
+	mu.Unlock()
+	return timer
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"donkey/lexer"
+	"donkey/object"
+	"donkey/parser"
+	"testing"
+	"time"
+)
+
+// withManualClock replaces the clock for the test and returns an evaluation function sharing one environment
+func withManualClock(t *testing.T) (*ManualClock, func(string) object.Object) {
+	t.Helper()
+	collectUnhandledErrors(t)
+
+	clock := NewManualClock(time.UnixMilli(1000))
+	SetClock(clock)
+	t.Cleanup(func() { SetClock(realClock{}) })
+
+	env := object.NewEnvironment()
+	return clock, func(input string) object.Object {
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+}
+
+func TestNow(t *testing.T) {
+	clock, eval := withManualClock(t)
+
+	testIntegerObject(t, eval("now()"), 1000)
+	clock.Advance(500 * time.Millisecond)
+	testIntegerObject(t, eval("now()"), 1500)
+}
+
+func TestAfter(t *testing.T) {
+	clock, eval := withManualClock(t)
+
+	eval("let hits = channel(10); let timer = after(100, fn() { hits.send(now()) });")
+
+	clock.Advance(99 * time.Millisecond)
+	testIntegerObject(t, eval("hits.len()"), 0)
+
+	clock.Advance(time.Millisecond)
+	testIntegerObject(t, eval("hits.recv()"), 1100)
+	testBooleanObject(t, eval("timer.cancel()"), false)
+
+	eval("let cancelled = after(100, fn() { hits.send(1) });")
+	testBooleanObject(t, eval("cancelled.cancel()"), true)
+	clock.Advance(time.Second)
+
+	if running := WaitForTasks(time.Second); running != 0 {
+		t.Errorf("expected no pending timers. running=%d", running)
+	}
+	testIntegerObject(t, eval("hits.len()"), 0)
+}
+
+func TestEvery(t *testing.T) {
+	clock, eval := withManualClock(t)
+
+	// the callbacks run as async functions, so they only see the time after advancing
+	eval(`
+		let ticks = channel(20);
+		let timer = every(100, fn(self) {
+			ticks.send(1);
+			if (now() >= 1400) { self.cancel() }
+		});
+	`)
+
+	clock.Advance(350 * time.Millisecond)
+	testIntegerObject(t, eval("ticks.recv() + ticks.recv() + ticks.recv()"), 3)
+
+	if running := WaitForTasks(10 * time.Millisecond); running != 1 {
+		t.Errorf("expected the pending interval to keep the program alive. running=%d", running)
+	}
+	testIntegerObject(t, eval("ticks.len()"), 0)
+
+	clock.Advance(50 * time.Millisecond)
+	testIntegerObject(t, eval("ticks.recv()"), 1)
+	if running := WaitForTasks(time.Second); running != 0 {
+		t.Errorf("expected the interval to cancel itself. running=%d", running)
+	}
+	testBooleanObject(t, eval("timer.cancel()"), false)
+}
+
+func TestSleep(t *testing.T) {
+	clock, eval := withManualClock(t)
+
+	eval("let sleeper = async fn() { sleep(1000); now() }();")
+	for i := 0; clock.Pending() == 0 && i < 1000; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(time.Second)
+	testIntegerObject(t, eval("await sleeper"), 2000)
+}
+
+func TestTimersKeepProgramAlive(t *testing.T) {
+	collectUnhandledErrors(t)
+
+	// the main program blocks on the channel, but the pending timer will send to it
+	testIntegerObject(t, testEval("let ch = channel(); after(10, fn() { ch.send(7) }); ch.recv()"), 7)
+	testNullObject(t, testEval("sleep(1)"))
+}
+
+func TestTimerErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"sleep(-1)", "`sleep` duration must not be negative, got=-1"},
+		{`sleep("1")`, "argument to `sleep` must be INTEGER, got=STRING"},
+		{"after(1, 5)", "second argument to `after` must be FUNCTION, got=INTEGER"},
+		{"after(1, fn(a, b) {})", "function passed to `after` must take at most 1 argument, got=2"},
+		{"every(0, fn() {})", "`every` interval must be greater than 0"},
+		{"now(1)", "wrong number of arguments. got=1, want=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
@@ -0,0 +1,623 @@
+// Package formatter prints donkey programs in their canonical layout.
+//
+// Blocks are indented by two spaces, unless they hold a single expression that fits into maxWidth.
+// Call arguments, array elements and hash pairs are put on separate lines if they do not fit,
+// and parentheses are only kept where the parser's precedences need them. Comments and single blank lines between statements are preserved.
+package formatter
+
+import (
+	"donkey/ast"
+	"donkey/lexer"
+	"donkey/parser"
+	"donkey/token"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	indentation = "  "
+	maxWidth    = 80
+)
+
+// atom is the precedence of expressions that are complete on their own, like literals or calls
+const atom = parser.INDEX + 1
+
+// Format returns the formatted source, or the parse errors if the source is not a valid program.
+// The options are passed to the lexer, e.g. lexer.WithFile to name the file in errors.
+func Format(source string, opts ...lexer.Option) (string, []*parser.ParseError) {
+	p := parser.New(lexer.New(source, opts...))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", p.Errors()
+	}
+
+	pr := &printer{source: source, out: &strings.Builder{}, comments: collectComments(source)}
+	pr.program(program)
+	return pr.out.String(), nil
+}
+
+// collectComments returns the comment tokens of the source in order, the parser skips them
+func collectComments(source string) []token.Token {
+	var comments []token.Token
+	l := lexer.New(source, lexer.WithComments())
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.COMMENT {
+			comments = append(comments, tok)
+		}
+	}
+	return comments
+}
+
+type printer struct {
+	source string
+	out    *strings.Builder
+
+	comments []token.Token
+	next     int // index of the first comment that was not printed yet
+
+	depth         int
+	column        int  // width of the current line so far
+	pendingIndent bool // the current line is empty and still needs its indentation
+	flat          bool // lists are not broken into lines, used to measure their width
+	inline        bool // blocks are kept on one line where possible, used to measure an enclosing block
+}
+
+func (p *printer) write(s string) {
+	if s == "" {
+		return
+	}
+	if p.pendingIndent {
+		p.pendingIndent = false
+		p.write(strings.Repeat(indentation, p.depth))
+	}
+
+	p.out.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.column = utf8.RuneCountInString(s[i+1:])
+	} else {
+		p.column += utf8.RuneCountInString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.out.WriteString("\n")
+	p.column = 0
+	p.pendingIndent = true
+}
+
+// text returns the source of the token, which keeps e.g. escape sequences and number notations as written
+func (p *printer) text(tok token.Token) string {
+	return p.source[tok.Location.Offset:tok.Location.EndOffset]
+}
+
+// hasComment reports whether an unprinted comment starts before the offset
+func (p *printer) hasComment(before int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Location.Offset < before
+}
+
+// commentsBefore prints the comments starting before the offset on lines of their own.
+// A single blank line is kept above a comment if the source had one. It returns the last printed line.
+func (p *printer) commentsBefore(offset, lastLine int) int {
+	for p.hasComment(offset) {
+		comment := p.comments[p.next]
+		p.next++
+
+		if lastLine > 0 && comment.Location.Line > lastLine+1 {
+			p.newline()
+		}
+		p.write(strings.TrimRight(p.text(comment), " \t"))
+		p.newline()
+		lastLine = comment.Location.EndLine
+	}
+	return lastLine
+}
+
+// trailingComment appends a comment that follows on the given line, if it starts before the offset
+func (p *printer) trailingComment(line, before int) {
+	if p.hasComment(before) && p.comments[p.next].Location.Line == line {
+		p.write(" " + strings.TrimRight(p.text(p.comments[p.next]), " \t"))
+		p.next++
+	}
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, len(p.source), false)
+}
+
+// statements prints one statement per line, followed by the comments up to the end offset.
+// In blocks the last expression statement is the block's value and has no semicolon.
+func (p *printer) statements(statements []ast.Statement, end int, block bool) {
+	lastLine := 0
+	for i, stmt := range statements {
+		span := stmt.Span()
+		lastLine = p.commentsBefore(span.Offset, lastLine)
+		if lastLine > 0 && span.Line > lastLine+1 {
+			p.newline()
+		}
+
+		var next ast.Statement
+		nextOffset := end
+		if i+1 < len(statements) {
+			next = statements[i+1]
+			nextOffset = next.Span().Offset
+		}
+
+		p.statement(stmt)
+		if needsSemicolon(stmt, next, block) {
+			p.write(";")
+		}
+		p.trailingComment(span.EndLine, nextOffset)
+		p.newline()
+		lastLine = span.EndLine
+	}
+	p.commentsBefore(end, lastLine)
+}
+
+// needsSemicolon reports whether the statement has to be terminated. Expressions ending in a block
+// only need one if the next statement could otherwise continue them, e.g. as a call or an index.
+func needsSemicolon(stmt, next ast.Statement, block bool) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return true
+	}
+	if next == nil {
+		return !block && !endsWithBlock(es.Expression)
+	}
+	if !endsWithBlock(es.Expression) {
+		return true
+	}
+
+	following, ok := next.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	switch leadingText(following.Expression) {
+	case "(", "[", "-":
+		return true
+	}
+	return false
+}
+
+func endsWithBlock(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IfExpression, *ast.ForExpression, *ast.SelectExpression:
+		return true
+	}
+	return false
+}
+
+// leadingText returns how the formatted expression starts, if that is an opening bracket or an operator
+func leadingText(exp ast.Expression) string {
+	for {
+		var left ast.Expression
+		precedence := 0
+
+		switch node := exp.(type) {
+		case *ast.InfixExpression:
+			left, precedence = node.Left, parser.Precedence(node.Token.Type)
+		case *ast.AssignExpression:
+			left, precedence = node.Target, parser.ASSIGN
+		case *ast.CallExpression:
+			left, precedence = node.Function, parser.CALL
+		case *ast.IndexExpression:
+			left, precedence = node.Left, parser.INDEX
+		case *ast.MemberExpression:
+			left, precedence = node.Object, parser.INDEX
+		case *ast.PrefixExpression:
+			return node.Operator
+		case *ast.ArrayLiteral:
+			return "["
+		default:
+			return ""
+		}
+
+		if precedence > openEnd(left) {
+			return "("
+		}
+		exp = left
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch node := stmt.(type) {
+	case *ast.LetStatement:
+		if node.Exported {
+			p.write("export ")
+		}
+		p.write("let " + node.Name.Value + " = ")
+		p.expression(node.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(node.ReturnValue, parser.LOWEST)
+	case *ast.BreakStatement:
+		p.write("break")
+	case *ast.ContinueStatement:
+		p.write("continue")
+	case *ast.ExpressionStatement:
+		p.expression(node.Expression, parser.LOWEST)
+	}
+}
+
+// block prints the statements between braces. A single expression stays on the line of the braces if it fits.
+func (p *printer) block(block *ast.BlockStatement) {
+	start, end := block.Token.Location, block.RBrace.Location
+
+	if len(block.Statements) == 0 && !p.hasComment(end.Offset) {
+		p.write("{}")
+		return
+	}
+	if exp := inlineExpression(block); exp != nil && !p.hasComment(end.Offset) {
+		fits := p.inline
+		if !fits {
+			// measure the block written in one line, nested blocks fit if it does
+			trial := *p
+			trial.out, trial.flat, trial.inline = &strings.Builder{}, true, true
+			trial.inlineBlock(exp)
+			inlined := trial.out.String()
+			fits = !strings.Contains(inlined, "\n") && p.column+utf8.RuneCountInString(inlined) <= maxWidth
+		}
+		if fits {
+			p.inlineBlock(exp)
+			return
+		}
+	}
+
+	first := end.Offset
+	if len(block.Statements) > 0 {
+		first = block.Statements[0].Span().Offset
+	}
+	p.write("{")
+	p.trailingComment(start.Line, first)
+	p.newline()
+	p.depth++
+	p.statements(block.Statements, end.Offset, true)
+	p.depth--
+	p.write("}")
+}
+
+// inlineExpression returns the expression of a block that consists of a single expression
+// statement, or nil if the block can not be written in one line
+func inlineExpression(block *ast.BlockStatement) ast.Expression {
+	if len(block.Statements) != 1 {
+		return nil
+	}
+	if es, ok := block.Statements[0].(*ast.ExpressionStatement); ok && !endsWithBlock(es.Expression) {
+		return es.Expression
+	}
+	return nil
+}
+
+func (p *printer) inlineBlock(exp ast.Expression) {
+	p.write("{ ")
+	p.expression(exp, parser.LOWEST)
+	p.write(" }")
+}
+
+// expression prints the expression as the operand of an operator, which was parsed with the given precedence.
+// It is put into parentheses if it would not be parsed as a whole otherwise.
+func (p *printer) expression(exp ast.Expression, precedence int) {
+	if binding(exp) <= precedence {
+		p.write("(")
+		p.expression(exp, parser.LOWEST)
+		p.write(")")
+		return
+	}
+
+	switch node := exp.(type) {
+	case *ast.Identifier:
+		p.write(node.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		span := exp.Span()
+		p.write(p.source[span.Offset:span.EndOffset])
+	case *ast.InterpolatedString:
+		p.interpolatedString(node)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", node.Token.Location, node.RBracket.Location, spans(node.Elements), func(p *printer, i int) {
+			p.expression(node.Elements[i], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.hash(node)
+	case *ast.FunctionLiteral:
+		if node.Body.Async {
+			p.write("async ")
+		}
+		p.write("fn")
+		p.parameters(node.Parameters)
+		p.block(node.Body)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.parameters(node.Parameters)
+		p.block(node.Body)
+	case *ast.PrefixExpression:
+		p.write(node.Operator)
+		p.expression(node.Right, parser.PREFIX)
+	case *ast.AwaitExpression:
+		p.write("await ")
+		p.expression(node.Value, parser.PREFIX)
+	case *ast.InfixExpression:
+		precedence := parser.Precedence(node.Token.Type)
+		p.operand(node.Left, precedence)
+		p.write(" " + node.Operator + " ")
+		// ** is right associative
+		if node.Token.Type == token.POWER {
+			precedence--
+		}
+		p.expression(node.Right, precedence)
+	case *ast.AssignExpression:
+		p.operand(node.Target, parser.ASSIGN)
+		p.write(" " + node.Operator + " ")
+		p.expression(node.Value, parser.ASSIGN-1)
+	case *ast.CallExpression:
+		p.operand(node.Function, parser.CALL)
+		p.list("(", ")", node.Token.Location, node.RParen.Location, spans(node.Arguments), func(p *printer, i int) {
+			p.expression(node.Arguments[i], parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		p.operand(node.Left, parser.INDEX)
+		p.write("[")
+		p.expression(node.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.MemberExpression:
+		p.operand(node.Object, parser.INDEX)
+		p.write("." + node.Property.Value)
+	case *ast.IfExpression:
+		p.ifExpression(node)
+	case *ast.ForExpression:
+		p.write("for (")
+		if node.Iterable != nil {
+			if node.Key != nil {
+				p.write(node.Key.Value + ", ")
+			}
+			p.write(node.Value.Value + " in ")
+			p.expression(node.Iterable, parser.LOWEST)
+		} else {
+			p.expression(node.Condition, parser.LOWEST)
+		}
+		p.write(") ")
+		p.block(node.Body)
+	case *ast.SelectExpression:
+		p.selectExpression(node)
+	case *ast.ImportExpression:
+		p.write("import " + p.text(node.Path.Token))
+		if node.Alias != nil {
+			p.write(" as " + node.Alias.Value)
+		}
+	}
+}
+
+// operand prints the left operand of an operator with the given precedence. It needs parentheses
+// if its own trailing operand would otherwise take the operator, like `-a` in `(-a) ** 2`.
+func (p *printer) operand(exp ast.Expression, precedence int) {
+	if precedence > openEnd(exp) {
+		p.write("(")
+		p.expression(exp, parser.LOWEST)
+		p.write(")")
+		return
+	}
+	p.expression(exp, parser.LOWEST)
+}
+
+// binding returns the precedence of the operator that has to take the expression as its operand.
+// Prefix operators and literals start a new expression, they never need parentheses as right operands.
+func binding(exp ast.Expression) int {
+	switch node := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(node.Token.Type)
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	}
+	return atom
+}
+
+// openEnd returns the precedence the trailing operand of the expression was parsed with.
+// Operators that bind tighter than that continue the trailing operand.
+func openEnd(exp ast.Expression) int {
+	switch node := exp.(type) {
+	case *ast.InfixExpression:
+		if node.Token.Type == token.POWER {
+			return parser.POWER - 1
+		}
+		return parser.Precedence(node.Token.Type)
+	case *ast.AssignExpression:
+		return parser.ASSIGN - 1
+	case *ast.PrefixExpression, *ast.AwaitExpression:
+		return parser.PREFIX
+	}
+	return atom
+}
+
+func (p *printer) parameters(params []*ast.Identifier) {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Value
+	}
+	p.write("(" + strings.Join(names, ", ") + ") ")
+}
+
+func (p *printer) ifExpression(node *ast.IfExpression) {
+	p.write("if (")
+	p.expression(node.Condition, parser.LOWEST)
+	p.write(") ")
+	p.block(node.Consequence)
+
+	switch {
+	case node.AlternativeIf != nil:
+		p.write(" else ")
+		p.ifExpression(node.AlternativeIf)
+	case node.Alternative != nil:
+		p.write(" else ")
+		p.block(node.Alternative)
+	}
+}
+
+func (p *printer) selectExpression(node *ast.SelectExpression) {
+	p.write("select {")
+	p.newline()
+	p.depth++
+
+	lastLine := 0
+	for _, c := range node.Cases {
+		lastLine = p.commentsBefore(c.Token.Location.Offset, lastLine)
+		p.write("case ")
+		if c.Name != nil {
+			p.write(c.Name.Value + " = ")
+		}
+		p.operand(c.Channel, parser.INDEX)
+		if c.Value != nil {
+			p.write(".send(")
+			p.expression(c.Value, parser.LOWEST)
+			p.write(") ")
+		} else {
+			p.write(".recv() ")
+		}
+		p.block(c.Body)
+		p.newline()
+		lastLine = c.Body.RBrace.Location.Line
+	}
+	if node.Default != nil {
+		p.commentsBefore(node.Default.Token.Location.Offset, lastLine)
+		p.write("default ")
+		p.block(node.Default)
+		p.newline()
+	}
+
+	p.commentsBefore(node.RBrace.Location.Offset, 0)
+	p.depth--
+	p.write("}")
+}
+
+// interpolatedString prints the text parts as written and formats the embedded expressions.
+// The parts are found by lexing the string again, only the outermost string's parts are used.
+func (p *printer) interpolatedString(node *ast.InterpolatedString) {
+	start, end := node.Token.Location.Offset, node.End.Location.EndOffset
+	l := lexer.New(p.source[start:end])
+
+	var parts []string
+	depth := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.TEMPLATE_START:
+			depth++
+		case token.TEMPLATE_END:
+			depth--
+		}
+		text := p.source[start+tok.Location.Offset : start+tok.Location.EndOffset]
+		if depth == 1 && tok.Type == token.TEMPLATE_START || depth == 1 && tok.Type == token.TEMPLATE_PART ||
+			depth == 0 && tok.Type == token.TEMPLATE_END {
+			parts = append(parts, text)
+		}
+	}
+
+	for i, exp := range node.Expressions {
+		p.write(parts[i])
+		p.expression(exp, parser.LOWEST)
+	}
+	p.write(parts[len(parts)-1])
+}
+
+func (p *printer) hash(node *ast.HashLiteral) {
+	keys := make([]ast.Expression, 0, len(node.Pairs))
+	for key := range node.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Span().Offset < keys[j].Span().Offset })
+
+	items := make([]token.TokenLocation, len(keys))
+	for i, key := range keys {
+		items[i] = key.Span().Through(node.Pairs[key].Span())
+	}
+
+	p.list("{", "}", node.Token.Location, node.RBrace.Location, items, func(p *printer, i int) {
+		p.expression(keys[i], parser.LOWEST)
+		p.write(": ")
+		p.expression(node.Pairs[keys[i]], parser.LOWEST)
+	})
+}
+
+// list prints the items separated by commas, write prints the item with the given printer. They are put on lines of their own if the list does not fit
+// into the line or if comments are placed between them. The parser does not accept trailing commas.
+func (p *printer) list(open, close string, start, end token.TokenLocation, items []token.TokenLocation, write func(*printer, int)) {
+	if len(items) == 0 {
+		p.write(open + close)
+		return
+	}
+
+	broken := p.commentBetween(items, end.Offset)
+	if !broken && !p.flat {
+		// measure the first line of the list written in one line
+		trial := *p
+		trial.out, trial.flat = &strings.Builder{}, true
+		trial.list(open, close, start, end, items, write)
+		first, _, _ := strings.Cut(trial.out.String(), "\n")
+		broken = p.column+utf8.RuneCountInString(first) > maxWidth
+	}
+
+	p.write(open)
+	if !broken {
+		for i := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			write(p, i)
+		}
+		p.write(close)
+		return
+	}
+
+	p.trailingComment(start.Line, items[0].Offset)
+	p.newline()
+	p.depth++
+
+	lastLine := 0
+	for i, span := range items {
+		lastLine = p.commentsBefore(span.Offset, lastLine)
+
+		nextOffset := end.Offset
+		if i+1 < len(items) {
+			nextOffset = items[i+1].Offset
+		}
+
+		write(p, i)
+		if i+1 < len(items) {
+			p.write(",")
+		}
+		p.trailingComment(span.EndLine, nextOffset)
+		p.newline()
+		lastLine = span.EndLine
+	}
+
+	p.commentsBefore(end.Offset, lastLine)
+	p.depth--
+	p.write(close)
+}
+
+// commentBetween reports whether an unprinted comment before the end is placed outside of all items.
+// Comments within an item, e.g. in a function body, are printed by the item itself.
+func (p *printer) commentBetween(items []token.TokenLocation, end int) bool {
+	for _, comment := range p.comments[p.next:] {
+		offset := comment.Location.Offset
+		if offset >= end {
+			return false
+		}
+
+		inside := false
+		for _, item := range items {
+			if item.Offset <= offset && offset < item.EndOffset {
+				inside = true
+			}
+		}
+		if !inside {
+			return true
+		}
+	}
+	return false
+}
+
+func spans(expressions []ast.Expression) []token.TokenLocation {
+	locations := make([]token.TokenLocation, len(expressions))
+	for i, exp := range expressions {
+		locations[i] = exp.Span()
+	}
+	return locations
+}
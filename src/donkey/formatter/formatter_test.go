@@ -0,0 +1,244 @@
+package formatter
+
+import (
+	"donkey/lexer"
+	"donkey/parser"
+	"strings"
+	"testing"
+)
+
+func testFormat(t *testing.T, input, expected string) {
+	t.Helper()
+
+	formatted, errs := Format(input)
+	if errs != nil {
+		t.Fatalf("%q: unexpected parse errors %v", input, errs)
+	}
+	if formatted != expected {
+		t.Errorf("wrong formatting of %q.\nwant=\n%s\ngot=\n%s", input, expected, formatted)
+	}
+
+	again, _ := Format(formatted)
+	if again != formatted {
+		t.Errorf("formatting %q is not stable.\nfirst=\n%s\nsecond=\n%s", input, formatted, again)
+	}
+}
+
+type formatTest struct {
+	input    string
+	expected string
+}
+
+var formatTests = []formatTest{
+	{"let   x=1+2*3", "let x = 1 + 2 * 3;\n"},
+	{"export let x = 1;", "export let x = 1;\n"},
+	{"let add=fn(a,b){a+b}", "let add = fn(a, b) { a + b };\n"},
+	{"let f = fn() {\nlet x = 1;\nreturn x\n};", "let f = fn() {\n  let x = 1;\n  return x;\n};\n"},
+	{"let f = fn() { let x = 1; x }", "let f = fn() {\n  let x = 1;\n  x\n};\n"},
+	{"let f = fn() {}", "let f = fn() {};\n"},
+	{"let m = macro(a) { quote(unquote(a) + 1) }", "let m = macro(a) { quote(unquote(a) + 1) };\n"},
+	{"let f = async fn() { await g() }", "let f = async fn() { await g() };\n"},
+	{"if (a) { 1 } else { 2 }", "if (a) { 1 } else { 2 }\n"},
+	{"if a > 1 {\nb\n} else if a < 0 { c } else {\nd\n}", "if (a > 1) { b } else if (a < 0) { c } else { d }\n"},
+	{"for (x in [1, 2]) { if (x == 1) { break } }", "for (x in [1, 2]) {\n  if (x == 1) {\n    break;\n  }\n}\n"},
+	{"for (k,v in h) {\nprint(k)\n}", "for (k, v in h) { print(k) }\n"},
+	{"for (i < 10) { i += 1 }", "for (i < 10) { i += 1 }\n"},
+	{"select { case msg = ch.recv() { msg } case ch.send(1) {\nnull\n} default { 0 } }",
+		"select {\n  case msg = ch.recv() { msg }\n  case ch.send(1) { null }\n  default { 0 }\n}\n"},
+	{`let lib = import "lib.dk" as l; lib.x`, "let lib = import \"lib.dk\" as l;\nlib.x;\n"},
+	{`let h = {"b": 1, "a": [1,2], 3: true}`, "let h = {\"b\": 1, \"a\": [1, 2], 3: true};\n"},
+	{`let s = "tab\té ${ x+1 } and ${ "${y}" }!"`, "let s = \"tab\\té ${x + 1} and ${\"${y}\"}!\";\n"},
+	{"let r = `raw ${x}`", "let r = `raw ${x}`;\n"},
+	{"let f = 1.50; let i = 007", "let f = 1.50;\nlet i = 007;\n"},
+	{"x = y = 1; a[0] += 2; o.field = 3", "x = y = 1;\na[0] += 2;\no.field = 3;\n"},
+	{"f()(1)[2].x", "f()(1)[2].x;\n"},
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range formatTests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+func TestMinimalParentheses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"(1 - 2) - 3", "1 - 2 - 3;\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3);\n"},
+		{"((a))", "a;\n"},
+		{"2 ** (3 ** 2)", "2 ** 3 ** 2;\n"},
+		{"(2 ** 3) ** 2", "(2 ** 3) ** 2;\n"},
+		{"(-2) ** 2", "(-2) ** 2;\n"},
+		{"-(2 ** 2)", "-2 ** 2;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"(-a) * b", "-a * b;\n"},
+		{"-(-a)", "--a;\n"},
+		{"!(a && b) || (c || d) && e", "!(a && b) || (c || d) && e;\n"},
+		{"(a | b) & c", "(a | b) & c;\n"},
+		{"(a << 1) + 1", "(a << 1) + 1;\n"},
+		{"a << (1 + 1)", "a << 1 + 1;\n"},
+		{"(a == b) == c", "a == b == c;\n"},
+		{"(f)(1)", "f(1);\n"},
+		{"(a + b)(1)", "(a + b)(1);\n"},
+		{"(-a)[0]", "(-a)[0];\n"},
+		{"(f()).x", "f().x;\n"},
+		{"(await f).x", "(await f).x;\n"},
+		{"await (f().x)", "await f().x;\n"},
+		{"(x = 1) + 1", "(x = 1) + 1;\n"},
+		{"x = (y + 1)", "x = y + 1;\n"},
+		{"(fn(x) { x })(1)", "fn(x) { x }(1);\n"},
+		{"[(1 + 2)][(0)]", "[1 + 2][0];\n"},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+func TestSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// the block's value has no semicolon, statements before it do
+		{"fn() { a; b; }", "fn() {\n  a;\n  b\n};\n"},
+		// without a semicolon the array would index the if expression
+		{"if (a) { 1 };\n[1].len()", "if (a) { 1 };\n[1].len();\n"},
+		{"if (a) { 1 };\n(b + c) * 2", "if (a) { 1 };\n(b + c) * 2;\n"},
+		{"if (a) { 1 };\n-b", "if (a) { 1 };\n-b;\n"},
+		{"if (a) { 1 }\nb", "if (a) { 1 }\nb;\n"},
+		{"for (a) { 1 }\nlet b = 1;", "for (a) { 1 }\nlet b = 1;\n"},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#!/usr/bin/env donkey\n// adds\nlet a = 1;   // one  \n", "#!/usr/bin/env donkey\n// adds\nlet a = 1; // one\n"},
+		{"let a = 1;\n\n\n// b\n\nlet b = 2;\n\nlet c = 3; /* c */\n// end\n",
+			"let a = 1;\n\n// b\n\nlet b = 2;\n\nlet c = 3; /* c */\n// end\n"},
+		{"let f = fn() { // note\n  /* before */\n  x // value\n  // after\n}",
+			"let f = fn() { // note\n  /* before */\n  x // value\n  // after\n};\n"},
+		{"let f = fn() {\n  // nothing yet\n}", "let f = fn() {\n  // nothing yet\n};\n"},
+		{"let a = [1, // one\n2, /* two */ 3]", "let a = [\n  1, // one\n  2, /* two */\n  3\n];\n"},
+		{"f(1,\n  // last\n  2)", "f(\n  1,\n  // last\n  2\n);\n"},
+		{"select {\n  // first\n  case ch.recv() { 1 }\n  // none\n  default { 2 }\n  // end\n}",
+			"select {\n  // first\n  case ch.recv() { 1 }\n  // none\n  default { 2 }\n  // end\n}\n"},
+		{"// only a comment", "// only a comment\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+var lineBreakingTests = []formatTest{
+	{"let result = someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree);",
+		"let result = someFunction(\n  argumentNumberOne,\n  argumentNumberTwo,\n  argumentNumberThree\n);\n"},
+	{"let names = [\"first name\", \"second name\", \"third name\", \"fourth name\", \"fifth name\"];",
+		"let names = [\n  \"first name\",\n  \"second name\",\n  \"third name\",\n  \"fourth name\",\n  \"fifth name\"\n];\n"},
+	{"let h = {\"first key\": \"first value\", \"second key\": \"second value\", \"third key\": 3};",
+		"let h = {\n  \"first key\": \"first value\",\n  \"second key\": \"second value\",\n  \"third key\": 3\n};\n"},
+	// only the list that does not fit is broken
+	{"let result = outer(inner(1, 2), anotherArgument, yetAnotherArgument, andTheLastOne);",
+		"let result = outer(\n  inner(1, 2),\n  anotherArgument,\n  yetAnotherArgument,\n  andTheLastOne\n);\n"},
+	// a function as argument keeps the call on its line
+	{"let t = every(1000, fn() {\nfetch(\"https://example.com/a/rather/long/url/to/fetch/from\")\n});",
+		"let t = every(1000, fn() {\n  fetch(\"https://example.com/a/rather/long/url/to/fetch/from\")\n});\n"},
+	{"let short = [\n1,\n2\n];", "let short = [1, 2];\n"},
+	// a block that does not fit is broken instead of its content
+	{`if x > 1 { print("big") } else if x < 0 { print("neg") } else { print("small ${x + 1}") }`,
+		"if (x > 1) { print(\"big\") } else if (x < 0) { print(\"neg\") } else {\n  print(\"small ${x + 1}\")\n}\n"},
+	{"let g = fn() { someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree) }",
+		"let g = fn() {\n  someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree)\n};\n"},
+}
+
+func TestLineBreaking(t *testing.T) {
+	for _, tt := range lineBreakingTests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+// TestFormatIsIdempotent checks that formatted code stays as it is, independent of the input's layout
+func TestFormatIsIdempotent(t *testing.T) {
+	var inputs []string
+	for _, tt := range append(formatTests, lineBreakingTests...) {
+		inputs = append(inputs, tt.input)
+	}
+	inputs = append(inputs,
+		"if x > 1 {\nprint(\"big\")\n} else if x < 0 {\nprint(\"neg\")\n} else {\nprint(\"small ${x + 1}\")\n}",
+		"let t = every(1000, fn() { fetch(\"https://example.com/a/rather/long/url/to/fetch/from\") });",
+		"let g = fn() {\nsomeFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree)\n}",
+	)
+
+	for _, input := range inputs {
+		once, errs := Format(input)
+		if errs != nil {
+			t.Fatalf("%q: unexpected parse errors %v", input, errs)
+		}
+		if twice, _ := Format(once); twice != once {
+			t.Errorf("formatting %q is not idempotent.\nfirst=\n%s\nsecond=\n%s", input, once, twice)
+		}
+	}
+}
+
+// TestFormatKeepsMeaning checks that the formatted programs parse into the same syntax trees
+func TestFormatKeepsMeaning(t *testing.T) {
+	inputs := []string{
+		"let x = ((1 + 2) * 3) - (4 - 5) / 2 % 3;",
+		"let y = (-2) ** 2 + 2 ** (3 ** 2) + (2 ** 3) ** 2 - -1;",
+		"let z = !(a && b) || (c || d) && !e | f ^ g & h << 1 >> 2 == 3 != 4 < 5 <= 6 > 7 >= 8;",
+		"x = y += z; a[1][2] = b.c.d = (e = 1);",
+		`let s = "a\n${ x + 1 }b${ {"k": 1}["k"] }c${"${"${1}"}"}";`,
+		"if (x > 1) { puts(x) } else if x < 0 { -x } else { 0 };\n[1, 2].map(fn(v) { v * 2 })",
+		"for (k, v in {1: [2, 3]}) { if (v == 1) { continue; } print(k) } for (true) { break; }",
+		"let f = async fn(a) { let b = await g(a); select { case m = ch.recv() { m } case ch.send(b) { b } } };",
+		"export let m = macro(a, b) { quote(unquote(a) + unquote(b)) }; m(1, 2)",
+		"(fn() { 1 })(); (if (a) { fn(x) { x } } else { fn(x) { -x } })(2); await (f)()",
+		"let long = someFunction(argumentNumberOne, [argumentNumberTwo, argumentNumberThree], four, five);",
+	}
+
+	for _, input := range inputs {
+		expected := parse(t, input)
+
+		formatted, errs := Format(input)
+		if errs != nil {
+			t.Fatalf("%q: unexpected parse errors %v", input, errs)
+		}
+		if got := parse(t, formatted); got != expected {
+			t.Errorf("formatting changed the meaning of %q.\nwant=%s\ngot=%s\nformatted=\n%s", input, expected, got, formatted)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: unexpected parse errors %v", input, p.Errors())
+	}
+	return program.String()
+}
+
+func TestFormatParseErrors(t *testing.T) {
+	_, errs := Format("let x = ;\nlet y = 1;", lexer.WithFile("broken.dk"))
+	if len(errs) != 1 {
+		t.Fatalf("wrong number of errors. got=%v", errs)
+	}
+	if errs[0].Start.File != "broken.dk" || !strings.Contains(errs[0].Message, "no prefix parse function") {
+		t.Errorf("wrong error. got=%+v", errs[0])
+	}
+}
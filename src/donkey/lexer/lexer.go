@@ -2,6 +2,8 @@ package lexer
 
 import (
 	"donkey/token"
+	"fmt"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -13,11 +15,36 @@ type Lexer struct {
 	char        rune // current character under examination
 	Line        int  // current reading line
 	Column      int  // current column in the current line
-	StartColumn int  // current column in the current line
+	StartLine   int  // line the current token starts on
+	StartColumn int  // column the current token starts on
+	startPos    int  // byte offset the current token starts at
+	file        string
+
+	templateDepths []int // open brace count per embedded string expression, innermost last
+	emitComments   bool  // whether comments are returned as COMMENT tokens instead of being skipped
+}
+
+type Option func(*Lexer)
+
+// WithFile sets the file name all token locations refer to
+func WithFile(name string) Option {
+	return func(l *Lexer) {
+		l.file = name
+	}
 }
 
-func New(input string) *Lexer {
+// WithComments makes the lexer return comments as COMMENT tokens, e.g. for formatters
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
+}
+
+func New(input string, opts ...Option) *Lexer {
 	l := &Lexer{input: input, Line: 1}
+	for _, opt := range opts {
+		opt(l)
+	}
 	l.readChar()
 	return l
 }
@@ -46,8 +73,26 @@ func (l *Lexer) peekChar() rune {
 	}
 }
 
+// peekCharAt looks n characters ahead of the current one, peekCharAt(1) equals peekChar()
+func (l *Lexer) peekCharAt(n int) rune {
+	pos := l.pos
+	for i := 0; i < n; i++ {
+		if pos >= len(l.input) {
+			return 0
+		}
+		_, size := utf8.DecodeRuneInString(l.input[pos:])
+		pos += size
+	}
+	if pos >= len(l.input) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[pos:])
+	return r
+}
+
 func (l *Lexer) newTok(tokenType token.TokenType, literal string) token.Token {
-	return token.Token{Type: tokenType, Literal: literal, Location: token.TokenLocation{Line: l.Line, Column: l.StartColumn}}
+	loc := token.TokenLocation{File: l.file, Line: l.StartLine, Column: l.StartColumn, Offset: l.startPos}
+	return token.Token{Type: tokenType, Literal: literal, Location: loc}
 }
 
 func (l *Lexer) newToken(tokenType token.TokenType, char rune) token.Token {
@@ -69,44 +114,297 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[pos:l.pos]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads integers like `12` and floats like `1.5`, `2e10` or `1.5E-3`
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	pos := l.pos
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	// a dot without a following digit is not part of the number
+	if l.char == '.' && unicode.IsDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.char == 'e' || l.char == 'E' {
+		next := l.peekChar()
+		if unicode.IsDigit(next) || ((next == '+' || next == '-') && unicode.IsDigit(l.peekCharAt(2))) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.char == '+' || l.char == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return tokenType, l.input[pos:l.pos]
+}
+
+func (l *Lexer) readDigits() {
 	for unicode.IsDigit(l.char) {
 		l.readChar()
 	}
-	return l.input[pos:l.pos]
 }
 
-// TODO add support for char escaping "hello \" test" "hello\t\n\ttest"
-func (l *Lexer) readString() string {
+// how a scanned piece of string content ended
+type stringEnd int
+
+const (
+	stringEndQuote         stringEnd = iota // closing `"`
+	stringEndInterpolation                  // `${` starting an embedded expression
+	stringEndUnterminated                   // newline or EOF
+)
+
+// readString reads a double quoted string and resolves its escape sequences.
+// Strings containing `${` are split into TEMPLATE_START, the embedded tokens,
+// TEMPLATE_PART for text between two embeddings and a final TEMPLATE_END.
+// Unterminated strings or invalid escapes result in an ILLEGAL token whose literal describes the problem.
+func (l *Lexer) readString() token.Token {
+	content, end, errMsg := l.readStringContent()
+
+	switch {
+	case end == stringEndUnterminated:
+		return l.newTok(token.ILLEGAL, "unterminated string")
+	case errMsg != "":
+		return l.newTok(token.ILLEGAL, errMsg)
+	case end == stringEndInterpolation:
+		l.templateDepths = append(l.templateDepths, 0)
+		return l.newTok(token.TEMPLATE_START, content)
+	default:
+		return l.newTok(token.STRING, content)
+	}
+}
+
+// readTemplateContinuation continues a string after the `}` closing an embedded expression
+func (l *Lexer) readTemplateContinuation() token.Token {
+	content, end, errMsg := l.readStringContent()
+
+	switch {
+	case end == stringEndUnterminated:
+		return l.newTok(token.ILLEGAL, "unterminated string")
+	case errMsg != "":
+		return l.newTok(token.ILLEGAL, errMsg)
+	case end == stringEndInterpolation:
+		l.templateDepths = append(l.templateDepths, 0)
+		return l.newTok(token.TEMPLATE_PART, content)
+	default:
+		return l.newTok(token.TEMPLATE_END, content)
+	}
+}
+
+// readStringContent reads string content starting after the current char, which is either
+// the opening quote or the `}` of an embedded expression. The terminating `"` or `${` is consumed.
+func (l *Lexer) readStringContent() (string, stringEnd, string) {
+	var out strings.Builder
+	errMsg := ""
+
+	for {
+		l.readChar()
+
+		switch l.char {
+		case '"':
+			l.readChar()
+			return out.String(), stringEndQuote, errMsg
+
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.readChar()
+				return out.String(), stringEndInterpolation, errMsg
+			}
+			out.WriteRune(l.char)
+
+		case 0, '\n':
+			// the newline is left for skipWhitespace, so line counting stays correct
+			return out.String(), stringEndUnterminated, errMsg
+
+		case '\\':
+			if next := l.peekChar(); next == 0 || next == '\n' {
+				continue
+			}
+			l.readChar()
+			escaped := l.char
+			char, ok := l.readEscape()
+			if !ok && errMsg == "" {
+				errMsg = fmt.Sprintf("invalid escape sequence \\%c in string", escaped)
+			}
+			out.WriteRune(char)
+
+		default:
+			out.WriteRune(l.char)
+		}
+	}
+}
+
+// readEscape resolves the escape sequence whose first char after the backslash is the current char
+func (l *Lexer) readEscape() (rune, bool) {
+	switch l.char {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '\\':
+		return '\\', true
+	case '"':
+		return '"', true
+	case '$':
+		return '$', true
+	case 'u':
+		return l.readUnicodeEscape()
+	default:
+		return l.char, false
+	}
+}
+
+// readUnicodeEscape reads the `{1F600}` part of a `\u{1F600}` escape sequence
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return utf8.RuneError, false
+	}
+	l.readChar()
+
+	var code rune
+	digits := 0
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+		code = code*16 + hexValue(l.char)
+		digits++
+		if digits > 6 {
+			return utf8.RuneError, false
+		}
+	}
+
+	if l.peekChar() != '}' || digits == 0 || !utf8.ValidRune(code) {
+		return utf8.RuneError, false
+	}
+	l.readChar()
+
+	return code, true
+}
+
+// readRawString reads a backtick string, which has no escape sequences and may span lines.
+// The closing backtick is consumed.
+func (l *Lexer) readRawString() token.Token {
 	pos := l.pos + 1
+
 	for {
 		l.readChar()
 
-		if l.char == '"' || l.char == 0 {
-			break
+		switch l.char {
+		case '`':
+			str := l.input[pos:l.pos]
+			l.readChar()
+			return l.newTok(token.STRING, str)
+
+		case 0:
+			return l.newTok(token.ILLEGAL, "unterminated string")
+
+		case '\n':
+			l.newLine()
 		}
 	}
-	return l.input[pos:l.pos]
+}
+
+func (l *Lexer) isCommentStart() bool {
+	return (l.char == '/' && (l.peekChar() == '/' || l.peekChar() == '*')) || l.isShebang()
+}
+
+// isShebang reports whether the input starts with a `#!` line, which makes donkey files executable scripts
+func (l *Lexer) isShebang() bool {
+	return l.pos == 0 && l.char == '#' && l.peekChar() == '!'
+}
+
+// readComment reads a `// line` or a nestable `/* block */` comment including its delimiters.
+// A leading `#!` line is read like a line comment. It reports false if a block comment is not closed before EOF.
+func (l *Lexer) readComment() (string, bool) {
+	pos := l.pos
+
+	if l.peekChar() == '/' || l.isShebang() {
+		for l.char != '\n' && l.char != 0 {
+			l.readChar()
+		}
+		return l.input[pos:l.pos], true
+	}
+
+	l.readChar()
+	l.readChar()
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.char == 0:
+			return l.input[pos:l.pos], false
+		case l.char == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.char == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		case l.char == '\n':
+			l.newLine()
+		}
+		l.readChar()
+	}
+	return l.input[pos:l.pos], true
+}
+
+func (l *Lexer) newLine() {
+	l.Line = l.Line + 1
+	l.Column = 0
 }
 
 func (l *Lexer) skipWhitespace() {
 	for unicode.IsSpace(l.char) {
 		if l.char == '\n' {
 			// reset new line
-			l.Line = l.Line + 1
-			l.Column = 0
+			l.newLine()
 		}
 
 		l.readChar()
 	}
 }
 
+func (l *Lexer) markTokenStart() {
+	l.StartLine = l.Line
+	l.StartColumn = l.Column
+	l.startPos = min(l.pos, len(l.input))
+}
+
+// NextToken returns the next token, its location ends where the lexer stopped reading it
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+
+	loc := &tok.Location
+	if tok.Type == token.EOF {
+		loc.EndLine, loc.EndColumn, loc.EndOffset = loc.Line, loc.Column, loc.Offset
+	} else {
+		loc.EndLine, loc.EndColumn, loc.EndOffset = l.Line, l.Column, min(l.pos, len(l.input))
+	}
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
-	l.StartColumn = l.Column
+	for l.isCommentStart() {
+		l.markTokenStart()
+
+		comment, ok := l.readComment()
+		if !ok {
+			return l.newTok(token.ILLEGAL, "unterminated comment")
+		}
+		if l.emitComments {
+			return l.newTok(token.COMMENT, comment)
+		}
+		l.skipWhitespace()
+	}
+
+	l.markTokenStart()
 
 	switch l.char {
 	case '=':
@@ -116,9 +414,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.newToken(token.ASSIGN, l.char)
 		}
 	case '+':
-		tok = l.newToken(token.PLUS, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = l.newToken(token.PLUS, l.char)
+		}
 	case '-':
-		tok = l.newToken(token.MINUS, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = l.newToken(token.MINUS, l.char)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			tok = l.newTwoCharToken(token.NOT_EQ)
@@ -126,25 +432,57 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.newToken(token.BANG, l.char)
 		}
 	case '/':
-		tok = l.newToken(token.SLASH, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = l.newToken(token.SLASH, l.char)
+		}
 	case '*':
-		tok = l.newToken(token.ASTERISK, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.ASTERISK_ASSIGN)
+		} else if l.peekChar() == '*' {
+			tok = l.newTwoCharToken(token.POWER)
+		} else {
+			tok = l.newToken(token.ASTERISK, l.char)
+		}
+	case '%':
+		tok = l.newToken(token.PERCENT, l.char)
 	case '<':
 		if l.peekChar() == '=' {
 			tok = l.newTwoCharToken(token.LT_EQ)
+		} else if l.peekChar() == '<' {
+			tok = l.newTwoCharToken(token.SHIFT_LEFT)
 		} else {
 			tok = l.newToken(token.LT, l.char)
 		}
 	case '>':
 		if l.peekChar() == '=' {
 			tok = l.newTwoCharToken(token.GT_EQ)
+		} else if l.peekChar() == '>' {
+			tok = l.newTwoCharToken(token.SHIFT_RIGHT)
 		} else {
 			tok = l.newToken(token.GT, l.char)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = l.newToken(token.BIT_AND, l.char)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = l.newToken(token.BIT_OR, l.char)
+		}
+	case '^':
+		tok = l.newToken(token.BIT_XOR, l.char)
 	case ';':
 		tok = l.newToken(token.SEMICOLON, l.char)
 	case ':':
 		tok = l.newToken(token.COLON, l.char)
+	case '.':
+		tok = l.newToken(token.DOT, l.char)
 	case ',':
 		tok = l.newToken(token.COMMA, l.char)
 	case '(':
@@ -152,15 +490,29 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = l.newToken(token.RPAREN, l.char)
 	case '{':
+		if depth := len(l.templateDepths); depth > 0 {
+			l.templateDepths[depth-1]++
+		}
 		tok = l.newToken(token.LBRACE, l.char)
 	case '}':
+		depth := len(l.templateDepths)
+		if depth > 0 && l.templateDepths[depth-1] == 0 {
+			// closes an embedded expression, the string continues
+			l.templateDepths = l.templateDepths[:depth-1]
+			return l.readTemplateContinuation()
+		}
+		if depth > 0 {
+			l.templateDepths[depth-1]--
+		}
 		tok = l.newToken(token.RBRACE, l.char)
 	case '[':
 		tok = l.newToken(token.LBRACKET, l.char)
 	case ']':
 		tok = l.newToken(token.RBRACKET, l.char)
 	case '"':
-		tok = l.newTok(token.STRING, l.readString())
+		return l.readString()
+	case '`':
+		return l.readRawString()
 	case 0:
 		tok = l.newTok(token.EOF, "")
 	default:
@@ -169,8 +521,8 @@ func (l *Lexer) NextToken() token.Token {
 			tT := token.LookupIdent(ident)
 			return l.newTok(tT, ident)
 		} else if unicode.IsDigit(l.char) {
-			tL := l.readNumber()
-			return l.newTok(token.INT, tL)
+			tT, tL := l.readNumber()
+			return l.newTok(tT, tL)
 		} else {
 			tok = l.newToken(token.ILLEGAL, l.char)
 		}
@@ -181,7 +533,22 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 func isValidIdentifierChar(char rune) bool {
-	return unicode.IsLetter(char) || hasEmoji(int(char))
+	return unicode.IsLetter(char) || char == '_' || hasEmoji(int(char))
+}
+
+func isHexDigit(char rune) bool {
+	return ('0' <= char && char <= '9') || ('a' <= char && char <= 'f') || ('A' <= char && char <= 'F')
+}
+
+func hexValue(char rune) rune {
+	switch {
+	case '0' <= char && char <= '9':
+		return char - '0'
+	case 'a' <= char && char <= 'f':
+		return char - 'a' + 10
+	default:
+		return char - 'A' + 10
+	}
 }
 
 func hasEmoji(charCode int) bool {
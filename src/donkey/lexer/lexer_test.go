@@ -16,7 +16,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -298,3 +298,535 @@ async fn test() {}
 		}
 	}
 }
+
+func TestLoopTokens(t *testing.T) {
+	input := `
+for (i, x in arr) {
+	break;
+	continue;
+}
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "i"},
+		{token.COMMA, ","},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "arr"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignTokens(t *testing.T) {
+	input := `x = 1; x += 1; x -= 1; x *= 1; x /= 1;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `42; 1.5; 0.25; 2e10; 1.5E-3; 4e+2; 1e; 7.a`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "42"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "1.5"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "0.25"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "2e10"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "1.5E-3"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "4e+2"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.IDENT, "a"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"hello \" test"`, token.STRING, `hello " test`},
+		{`"hello\t\n\ttest"`, token.STRING, "hello\t\n\ttest"},
+		{`"a\rb\\c"`, token.STRING, "a\rb\\c"},
+		{`"smile \u{1F600}"`, token.STRING, "smile 😀"},
+		{`"\u{41}\u{e9}"`, token.STRING, "Aé"},
+		{`"bad \q escape"`, token.ILLEGAL, `invalid escape sequence \q in string`},
+		{`"bad \u{110000}"`, token.ILLEGAL, `invalid escape sequence \u in string`},
+		{`"bad \u{}"`, token.ILLEGAL, `invalid escape sequence \u in string`},
+		{`"bad \u1F600"`, token.ILLEGAL, `invalid escape sequence \u in string`},
+		{`"unterminated`, token.ILLEGAL, "unterminated string"},
+		{`"unterminated \`, token.ILLEGAL, "unterminated string"},
+		{"`raw \\n \"string\"`", token.STRING, `raw \n "string"`},
+		{"`unterminated raw", token.ILLEGAL, "unterminated string"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("tests[%d] - expected EOF after string. got=%q", i, next.Type)
+		}
+	}
+}
+
+func TestStringLinesAndColumns(t *testing.T) {
+	input := "let a = `multi\nline`;\nlet b = \"open\nc;"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "a", 1, 5},
+		{token.ASSIGN, "=", 1, 7},
+		{token.STRING, "multi\nline", 1, 9},
+		{token.SEMICOLON, ";", 2, 6},
+		{token.LET, "let", 3, 1},
+		{token.IDENT, "b", 3, 5},
+		{token.ASSIGN, "=", 3, 7},
+		{token.ILLEGAL, "unterminated string", 3, 9},
+		{token.IDENT, "c", 4, 1},
+		{token.SEMICOLON, ";", 4, 2},
+		{token.EOF, "", 4, 3},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Location.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line number wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Location.Line)
+		}
+
+		if tok.Location.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column number wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Location.Column)
+		}
+	}
+}
+
+func TestInterpolatedStringTokens(t *testing.T) {
+	input := `"total: ${a + b}!" "${ {"k": 1}["k"] } and ${"in${x}"}" "\${no}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.TEMPLATE_START, "total: ", 1},
+		{token.IDENT, "a", 11},
+		{token.PLUS, "+", 13},
+		{token.IDENT, "b", 15},
+		{token.TEMPLATE_END, "!", 16},
+		{token.TEMPLATE_START, "", 20},
+		{token.LBRACE, "{", 24},
+		{token.STRING, "k", 25},
+		{token.COLON, ":", 28},
+		{token.INT, "1", 30},
+		{token.RBRACE, "}", 31},
+		{token.LBRACKET, "[", 32},
+		{token.STRING, "k", 33},
+		{token.RBRACKET, "]", 36},
+		{token.TEMPLATE_PART, " and ", 38},
+		{token.TEMPLATE_START, "in", 46},
+		{token.IDENT, "x", 51},
+		{token.TEMPLATE_END, "", 52},
+		{token.TEMPLATE_END, "", 54},
+		{token.STRING, "${no}", 57},
+		{token.EOF, "", 65},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Location.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column number wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Location.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let a = 10 / 2; // trailing comment
+/* block
+   /* nested */ still comment
+*/ a /* inline */ + 1;
+//`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "a", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "10", 2, 9},
+		{token.SLASH, "/", 2, 12},
+		{token.INT, "2", 2, 14},
+		{token.SEMICOLON, ";", 2, 15},
+		{token.IDENT, "a", 5, 4},
+		{token.PLUS, "+", 5, 19},
+		{token.INT, "1", 5, 21},
+		{token.SEMICOLON, ";", 5, 22},
+		{token.EOF, "", 6, 3},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Location.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line number wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Location.Line)
+		}
+
+		if tok.Location.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column number wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Location.Column)
+		}
+	}
+}
+
+func TestCommentTokens(t *testing.T) {
+	input := `// line
+a /* block /* nested */ */ b
+/* open`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "// line", 1, 1},
+		{token.IDENT, "a", 2, 1},
+		{token.COMMENT, "/* block /* nested */ */", 2, 3},
+		{token.IDENT, "b", 2, 28},
+		{token.ILLEGAL, "unterminated comment", 3, 1},
+	}
+
+	l := New(input, WithComments())
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Location.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line number wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Location.Line)
+		}
+
+		if tok.Location.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column number wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Location.Column)
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	input := "#!/usr/bin/env donkey\nlet x = 1; #!"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "1", 2, 9},
+		{token.SEMICOLON, ";", 2, 10},
+		// only the first line can be a shebang
+		{token.ILLEGAL, "#", 2, 12},
+		{token.BANG, "!", 2, 13},
+		{token.EOF, "", 2, 14},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Location.Line != tt.expectedLine || tok.Location.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - location wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Location.Line, tok.Location.Column)
+		}
+	}
+
+	tok := New(input, WithComments()).NextToken()
+	if tok.Type != token.COMMENT || tok.Literal != "#!/usr/bin/env donkey" {
+		t.Fatalf("shebang is not returned as comment. got=%q %q", tok.Type, tok.Literal)
+	}
+}
+
+func TestUnderscoreIdentifiers(t *testing.T) {
+	input := `_ + snake_case * _private`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "_"},
+		{token.PLUS, "+"},
+		{token.IDENT, "snake_case"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "_private"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := `a && b || c % d ** e & f | g ^ h << i >> j`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.POWER, "**"},
+		{token.IDENT, "e"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "f"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "g"},
+		{token.BIT_XOR, "^"},
+		{token.IDENT, "h"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.IDENT, "i"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENT, "j"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenRanges(t *testing.T) {
+	input := "let 🍌 = \"yé\";\n`a\nb` >= 1.5\n"
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLocation token.TokenLocation
+	}{
+		{token.LET, token.TokenLocation{File: "main.dk", Line: 1, Column: 1, Offset: 0, EndLine: 1, EndColumn: 4, EndOffset: 3}},
+		{token.IDENT, token.TokenLocation{File: "main.dk", Line: 1, Column: 5, Offset: 4, EndLine: 1, EndColumn: 6, EndOffset: 8}},
+		{token.ASSIGN, token.TokenLocation{File: "main.dk", Line: 1, Column: 7, Offset: 9, EndLine: 1, EndColumn: 8, EndOffset: 10}},
+		{token.STRING, token.TokenLocation{File: "main.dk", Line: 1, Column: 9, Offset: 11, EndLine: 1, EndColumn: 13, EndOffset: 16}},
+		{token.SEMICOLON, token.TokenLocation{File: "main.dk", Line: 1, Column: 13, Offset: 16, EndLine: 1, EndColumn: 14, EndOffset: 17}},
+		{token.STRING, token.TokenLocation{File: "main.dk", Line: 2, Column: 1, Offset: 18, EndLine: 3, EndColumn: 3, EndOffset: 23}},
+		{token.GT_EQ, token.TokenLocation{File: "main.dk", Line: 3, Column: 4, Offset: 24, EndLine: 3, EndColumn: 6, EndOffset: 26}},
+		{token.FLOAT, token.TokenLocation{File: "main.dk", Line: 3, Column: 7, Offset: 27, EndLine: 3, EndColumn: 10, EndOffset: 30}},
+		{token.EOF, token.TokenLocation{File: "main.dk", Line: 4, Column: 1, Offset: 31, EndLine: 4, EndColumn: 1, EndOffset: 31}},
+	}
+
+	l := New(input, WithFile("main.dk"))
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Location != tt.expectedLocation {
+			t.Fatalf("tests[%d] - location wrong. expected=%+v, got=%+v",
+				i, tt.expectedLocation, tok.Location)
+		}
+	}
+}
@@ -1,24 +1,10 @@
 package main
 
 import (
-	"donkey/constants"
-	"donkey/repl"
-	"fmt"
+	"donkey/cli"
 	"os"
-	"os/user"
 )
 
 func main() {
-	userr, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
-	fmt.Printf("Hello %s! This is the %s programming language!\n",
-		userr.Username, constants.LangName)
-	fmt.Printf("Feel free to type in commands\n")
-
-	repl.Start(os.Stdin, os.Stdout)
-
-	// clear console colors on close
-	defer func() { fmt.Println("\u001b[39m") }()
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
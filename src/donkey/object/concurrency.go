@@ -0,0 +1,390 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// All blocking operations, awaiting futures and channel operations, synchronize on a single lock.
+// Handing a value to a waiting task and marking that task as runnable again therefore happens
+// atomically, which makes the deadlock detection exact: once every running task is blocked,
+// no task is left that could ever wake one of them up.
+var sched = struct {
+	mu      sync.Mutex
+	tasks   int // running tasks, including the main program
+	blocked map[*waiter]struct{}
+	nextID  int
+}{tasks: 1, blocked: map[*waiter]struct{}{}}
+
+// TaskStarted registers a newly started async task for deadlock detection
+func TaskStarted() {
+	sched.mu.Lock()
+	sched.tasks++
+	sched.mu.Unlock()
+}
+
+// TaskDone unregisters a finished async task. If every remaining task is blocked, they are woken up with a deadlock error.
+func TaskDone() {
+	sched.mu.Lock()
+	sched.tasks--
+	detectDeadlock()
+	sched.mu.Unlock()
+}
+
+// waiter is a task parked on one or more futures or channels
+type waiter struct {
+	on   string // what the task is blocked on, for deadlock reports
+	wake chan struct{}
+	done bool
+
+	chosen int // index of the select case that completed
+	value  Object
+}
+
+func newWaiter(on string) *waiter {
+	return &waiter{on: on, wake: make(chan struct{})}
+}
+
+// complete wakes the waiter up, unless another operation already did. Requires sched.mu.
+func (w *waiter) complete(chosen int, value Object) bool {
+	if w.done {
+		return false
+	}
+	w.done = true
+	w.chosen = chosen
+	w.value = value
+	delete(sched.blocked, w)
+	close(w.wake)
+	return true
+}
+
+// block parks the calling task until its waiter is completed. It requires sched.mu and releases it.
+func block(w *waiter) {
+	sched.blocked[w] = struct{}{}
+	detectDeadlock()
+	sched.mu.Unlock()
+	Park(w.wake)
+}
+
+// ParkBlocked parks the calling task until done is closed and counts it as blocked meanwhile, so once all
+// other tasks block as well, they are woken up with a deadlock error. The calling task itself keeps waiting.
+func ParkBlocked(on string, done <-chan struct{}) {
+	for {
+		sched.mu.Lock()
+		w := newWaiter(on)
+		sched.blocked[w] = struct{}{}
+		detectDeadlock()
+		sched.mu.Unlock()
+
+		woken := make(chan struct{})
+		go func() {
+			select {
+			case <-done:
+			case <-w.wake:
+			}
+			close(woken)
+		}()
+		Park(woken)
+
+		sched.mu.Lock()
+		w.complete(-1, nil) // unregisters the waiter, unless the deadlock detection already did
+		sched.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		default:
+			// woken up by a deadlock, the other tasks are finishing with an error now
+		}
+	}
+}
+
+// detectDeadlock wakes up all blocked tasks with an error once no task is left running. Requires sched.mu.
+func detectDeadlock() {
+	if len(sched.blocked) == 0 || len(sched.blocked) < sched.tasks {
+		return
+	}
+
+	var on []string
+	for w := range sched.blocked {
+		on = append(on, w.on)
+	}
+	sort.Strings(on)
+
+	msg := "deadlock, all tasks are blocked: " + strings.Join(on, ", ")
+	for w := range sched.blocked {
+		// every task gets its own error, as the evaluator fills in the location
+		w.complete(-1, &Error{Message: msg})
+	}
+}
+
+// Future is the eventual result of an async function call. It is resolved exactly once,
+// either with the returned value or with the *Error the function failed with.
+type Future struct {
+	resolved  bool
+	result    Object
+	observed  bool // whether anyone ever asked for the result
+	waiters   []*waiter
+	callbacks []func(Object)
+}
+
+func NewFuture() *Future {
+	return &Future{}
+}
+
+func (f *Future) Type() ObjectType { return FUTURE_OBJ }
+func (f *Future) Inspect() string {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	if !f.resolved {
+		return "future(pending)"
+	}
+	return fmt.Sprintf("future(%s)", f.result.Inspect())
+}
+
+// Resolve sets the result and wakes up all waiters. Later calls are ignored.
+func (f *Future) Resolve(result Object) {
+	sched.mu.Lock()
+	if f.resolved {
+		sched.mu.Unlock()
+		return
+	}
+
+	f.resolved = true
+	f.result = result
+	for _, w := range f.waiters {
+		w.complete(0, result)
+	}
+	callbacks := f.callbacks
+	f.waiters, f.callbacks = nil, nil
+	sched.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(result)
+	}
+}
+
+// OnResolve calls the callback with the result once the future is resolved, without blocking the caller
+func (f *Future) OnResolve(callback func(Object)) {
+	sched.mu.Lock()
+	f.observed = true
+	if !f.resolved {
+		f.callbacks = append(f.callbacks, callback)
+		sched.mu.Unlock()
+		return
+	}
+	sched.mu.Unlock()
+
+	callback(f.result)
+}
+
+// Observed reports whether the result was ever awaited or passed on, e.g. to `all`.
+// Errors of unobserved futures would go unnoticed otherwise.
+func (f *Future) Observed() bool {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	return f.observed
+}
+
+// Wait blocks until the future is resolved and returns its result.
+// If that would never happen because all tasks are blocked, it returns a deadlock *Error instead.
+func (f *Future) Wait() Object {
+	sched.mu.Lock()
+	f.observed = true
+	if f.resolved {
+		sched.mu.Unlock()
+		return f.result
+	}
+
+	w := newWaiter("await future")
+	f.waiters = append(f.waiters, w)
+	block(w)
+	return w.value
+}
+
+// Channel passes values between tasks. Sending blocks until a receiver takes the value
+// or, for buffered channels, until there is room in the buffer.
+type Channel struct {
+	ID       int
+	Capacity int
+
+	buffer  []Object
+	closed  bool
+	senders []pendingOp
+	recvers []pendingOp
+}
+
+// pendingOp is a select case a waiter is parked on
+type pendingOp struct {
+	w      *waiter
+	chosen int
+	value  Object // the value to send
+}
+
+// ChannelOp is a single channel operation of a select. Value is nil for receiving.
+type ChannelOp struct {
+	Channel *Channel
+	Value   Object
+}
+
+func (op ChannelOp) String() string {
+	if op.Value == nil {
+		return "recv on " + op.Channel.Inspect()
+	}
+	return "send on " + op.Channel.Inspect()
+}
+
+func NewChannel(capacity int) *Channel {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	sched.nextID++
+	return &Channel{ID: sched.nextID, Capacity: capacity}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return fmt.Sprintf("channel#%d", c.ID) }
+
+// Len returns the amount of buffered values
+func (c *Channel) Len() int {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	return len(c.buffer)
+}
+
+// Send blocks until the value is delivered. It returns an *Error if the channel is closed or on deadlock.
+func (c *Channel) Send(value Object) Object {
+	_, res := Select([]ChannelOp{{Channel: c, Value: value}}, false)
+	return res
+}
+
+// Recv blocks until a value is available. It returns nil once the channel is closed and drained,
+// and an *Error on deadlock.
+func (c *Channel) Recv() Object {
+	_, res := Select([]ChannelOp{{Channel: c}}, false)
+	return res
+}
+
+// Close wakes up all receivers with nil and fails all pending senders
+func (c *Channel) Close() *Error {
+	sched.mu.Lock()
+	defer sched.mu.Unlock()
+
+	if c.closed {
+		return &Error{Message: "close of closed " + c.Inspect()}
+	}
+	c.closed = true
+
+	for _, r := range c.recvers {
+		r.w.complete(r.chosen, nil)
+	}
+	for _, s := range c.senders {
+		s.w.complete(s.chosen, &Error{Message: "send on closed " + c.Inspect()})
+	}
+	c.recvers, c.senders = nil, nil
+	return nil
+}
+
+// Select performs one of the ready operations, picked at random if several are ready.
+// If none is ready it returns -1 when there is a default branch, or blocks until one becomes ready.
+// The result is the received value (nil for sends and closed channels) or an *Error.
+func Select(ops []ChannelOp, hasDefault bool) (int, Object) {
+	sched.mu.Lock()
+
+	offset := 0
+	if len(ops) > 1 {
+		offset = currentScheduler().Intn(len(ops))
+	}
+	for i := range ops {
+		chosen := (offset + i) % len(ops)
+		if ok, res := ops[chosen].try(); ok {
+			sched.mu.Unlock()
+			return chosen, res
+		}
+	}
+
+	if hasDefault {
+		sched.mu.Unlock()
+		return -1, nil
+	}
+
+	w := newWaiter(describeOps(ops))
+	for i, op := range ops {
+		pending := pendingOp{w: w, chosen: i, value: op.Value}
+		if op.Value == nil {
+			op.Channel.recvers = append(op.Channel.recvers, pending)
+		} else {
+			op.Channel.senders = append(op.Channel.senders, pending)
+		}
+	}
+	block(w)
+	return w.chosen, w.value
+}
+
+// try performs the operation if that is possible without blocking. Requires sched.mu.
+func (op ChannelOp) try() (bool, Object) {
+	c := op.Channel
+
+	if op.Value == nil {
+		if len(c.buffer) > 0 {
+			value := c.buffer[0]
+			c.buffer = c.buffer[1:]
+			// a blocked sender can move its value into the free slot
+			if s, ok := popPending(&c.senders); ok {
+				c.buffer = append(c.buffer, s.value)
+				s.w.complete(s.chosen, nil)
+			}
+			return true, value
+		}
+		if s, ok := popPending(&c.senders); ok {
+			s.w.complete(s.chosen, nil)
+			return true, s.value
+		}
+		return c.closed, nil
+	}
+
+	if c.closed {
+		return true, &Error{Message: "send on closed " + c.Inspect()}
+	}
+	if r, ok := popPending(&c.recvers); ok {
+		r.w.complete(r.chosen, op.Value)
+		return true, nil
+	}
+	if len(c.buffer) < c.Capacity {
+		c.buffer = append(c.buffer, op.Value)
+		return true, nil
+	}
+	return false, nil
+}
+
+// popPending removes the first operation whose waiter was not already woken up by another select case
+func popPending(queue *[]pendingOp) (pendingOp, bool) {
+	for len(*queue) > 0 {
+		op := (*queue)[0]
+		*queue = (*queue)[1:]
+		if !op.w.done {
+			return op, true
+		}
+	}
+	return pendingOp{}, false
+}
+
+func describeOps(ops []ChannelOp) string {
+	switch len(ops) {
+	case 0:
+		return "empty select"
+	case 1:
+		return ops[0].String()
+	}
+
+	var parts []string
+	for _, op := range ops {
+		parts = append(parts, op.String())
+	}
+	return "select (" + strings.Join(parts, ", ") + ")"
+}
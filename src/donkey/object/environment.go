@@ -1,8 +1,20 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
+// Environment is a single scope of bindings. Async functions share their enclosing
+// environments with the code that called them, so every frame guards its own store
+// with a RW lock. Lookups walking the outer chain only ever hold one frame's lock at a time.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
+	file  string // source file of a module's top level environment
+
+	importers []string // files whose imports led to the module, outermost first
 }
 
 func NewEnvironment() *Environment {
@@ -10,6 +22,22 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+// NewFileEnvironment creates the top level environment for code read from a file.
+// Imports within that code resolve relative to the file.
+func NewFileEnvironment(file string) *Environment {
+	env := NewEnvironment()
+	env.file = file
+	return env
+}
+
+// NewModuleEnvironment creates the top level environment of an imported module.
+// The importers are kept to detect import cycles.
+func NewModuleEnvironment(file string, importers []string) *Environment {
+	env := NewFileEnvironment(file)
+	env.importers = importers
+	return env
+}
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -17,7 +45,10 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
+
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -25,6 +56,70 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
+
+// Modify rebinds an existing name in the closest environment that defines it, to the result of fn
+// applied to its current value. It reports false if the name is not defined anywhere in the outer chain.
+// The frame stays locked while fn runs, so concurrent changes like `x += 1` are not lost.
+// An *Error result is returned without being bound.
+func (e *Environment) Modify(name string, fn func(current Object) Object) (Object, bool) {
+	e.mu.Lock()
+	current, ok := e.store[name]
+	if ok {
+		val := fn(current)
+		if _, isErr := val.(*Error); !isErr {
+			e.store[name] = val
+		}
+		e.mu.Unlock()
+		return val, true
+	}
+	e.mu.Unlock()
+
+	if e.outer != nil {
+		return e.outer.Modify(name, fn)
+	}
+	return nil, false
+}
+
+// Names returns the sorted names bound in this environment or any outer one
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		for name := range env.store {
+			seen[name] = true
+		}
+		env.mu.RUnlock()
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File returns the source file of the closest environment that has one, or "" for e.g. the REPL
+func (e *Environment) File() string {
+	if e.file != "" || e.outer == nil {
+		return e.file
+	}
+	return e.outer.File()
+}
+
+// ImportChain returns the files whose imports led to the code of this environment, ending with its own file.
+// It is empty for code that was not read from a file.
+func (e *Environment) ImportChain() []string {
+	if e.file == "" {
+		if e.outer == nil {
+			return nil
+		}
+		return e.outer.ImportChain()
+	}
+	return append(e.importers[:len(e.importers):len(e.importers)], e.file)
+}
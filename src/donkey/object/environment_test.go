@@ -0,0 +1,93 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("shared", &Integer{Value: 0})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := NewEnclosedEnvironment(outer)
+			for j := 0; j < 100; j++ {
+				name := fmt.Sprintf("v%d_%d", i, j)
+				outer.Set(name, &Integer{Value: int64(j)})
+				env.Set(name, &Integer{Value: int64(j)})
+				if _, ok := env.Get(name); !ok {
+					t.Errorf("binding %s not found", name)
+				}
+				set := func(Object) Object { return &Integer{Value: int64(j)} }
+				if _, ok := env.Modify("shared", set); !ok {
+					t.Errorf("shared binding not found")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, ok := outer.Get("v49_99"); !ok {
+		t.Errorf("binding v49_99 not found")
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("shadowed", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("a", &Integer{Value: 3})
+	inner.Set("shadowed", &Integer{Value: 4})
+
+	expected := []string{"a", "b", "shadowed"}
+	if names := inner.Names(); fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("wrong names. want=%v, got=%v", expected, names)
+	}
+
+	if names := NewEnvironment().Names(); len(names) != 0 {
+		t.Errorf("empty environment has names. got=%v", names)
+	}
+}
+
+func TestEnvironmentModify(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("counter", &Integer{Value: 0})
+	increment := func(current Object) Object {
+		return &Integer{Value: current.(*Integer).Value + 1}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env := NewEnclosedEnvironment(outer)
+			for j := 0; j < 100; j++ {
+				env.Modify("counter", increment)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if counter, _ := outer.Get("counter"); counter.(*Integer).Value != 5000 {
+		t.Errorf("updates were lost. got=%d, want=5000", counter.(*Integer).Value)
+	}
+
+	failed, ok := outer.Modify("counter", func(current Object) Object { return &Error{Message: "no"} })
+	if _, isErr := failed.(*Error); !ok || !isErr {
+		t.Errorf("expected the error to be returned. got=%v", failed)
+	}
+	if counter, _ := outer.Get("counter"); counter.(*Integer).Value != 5000 {
+		t.Errorf("an error was bound. got=%v", counter)
+	}
+	if _, ok := outer.Modify("missing", increment); ok {
+		t.Errorf("missing binding was modified")
+	}
+}
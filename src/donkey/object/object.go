@@ -6,7 +6,11 @@ import (
 	"donkey/token"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type ObjectType string
@@ -15,20 +19,36 @@ const (
 	FUNCTION_OBJ     = "FUNCTION"
 	MACRO_OBJ        = "MACRO"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	STRING_OBJ       = "STRING"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	NULL_OBJ         = "NULL"
+	RANGE_OBJ        = "RANGE"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	BUILTIN_OBJ      = "BUILTIN"
 	QUOTE_OBJ        = "QUOTE"
+	MODULE_OBJ       = "MODULE"
+	FUTURE_OBJ       = "FUTURE"
+	CHANNEL_OBJ      = "CHANNEL"
+	TIMER_OBJ        = "TIMER"
 )
 
 type Error struct {
 	Message  string
 	Location *token.TokenLocation
+	Stack    []Frame  // the calls the error passed through, innermost first
+	Causes   []*Error // the errors that led to this one, e.g. the parse errors of an imported module
+}
+
+// Frame is a function call on the way from an error to the top level
+type Frame struct {
+	Function string // the name of the called function or `<anonymous>`
+	Location *token.TokenLocation
 }
 
 func (e *Error) Type() ObjectType {
@@ -39,7 +59,32 @@ func (e *Error) Inspect() string {
 	if e.Location != nil {
 		lineColumnInfo = fmt.Sprintf("\u001b[31mLine: %d, col: %d", e.Location.Line, e.Location.Column)
 	}
-	return fmt.Sprintf("Runtime error: %s >> %s", lineColumnInfo, e.Message) // TODO: add line + colmn
+	msg := fmt.Sprintf("Runtime error: %s >> %s", lineColumnInfo, e.Message)
+	if len(e.Stack) == 0 {
+		return msg
+	}
+	return msg + "\n" + e.Traceback()
+}
+
+// Traceback lists the calls the error passed through, it is empty for errors outside of functions
+func (e *Error) Traceback() string {
+	if len(e.Stack) == 0 {
+		return ""
+	}
+
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call first):")
+	for _, frame := range e.Stack {
+		out.WriteString("\n\tin " + frame.Function)
+		switch {
+		case frame.Location == nil:
+		case frame.Location.File != "":
+			out.WriteString(fmt.Sprintf(", called at %s:%d:%d", frame.Location.File, frame.Location.Line, frame.Location.Column))
+		default:
+			out.WriteString(fmt.Sprintf(", called at line %d, col %d", frame.Location.Line, frame.Location.Column))
+		}
+	}
+	return out.String()
 }
 
 type Object interface {
@@ -58,6 +103,27 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+func (f *Float) Inspect() string {
+	format := byte('f')
+	if abs := math.Abs(f.Value); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'g'
+	}
+
+	str := strconv.FormatFloat(f.Value, format, -1, 64)
+	// keep floats distinguishable from integers, e.g. 2.0 instead of 2
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+	return str
+}
+
 type String struct {
 	Value string
 }
@@ -80,7 +146,10 @@ func (b *Boolean) Inspect() string {
 	return fmt.Sprintf("%t", b.Value)
 }
 
+// Array is shared by reference, so async functions may change it concurrently. Once an array
+// is visible to evaluated code, its Elements must only be accessed through the methods.
 type Array struct {
+	mu       sync.RWMutex
 	Elements []Object
 }
 
@@ -91,7 +160,7 @@ func (ao *Array) Inspect() string {
 	var out bytes.Buffer
 
 	elements := []string{}
-	for _, e := range ao.Elements {
+	for _, e := range ao.Snapshot() {
 		elements = append(elements, e.Inspect())
 	}
 
@@ -102,6 +171,77 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+func (ao *Array) Len() int {
+	ao.mu.RLock()
+	defer ao.mu.RUnlock()
+	return len(ao.Elements)
+}
+
+// Snapshot returns a copy of the elements, to iterate them without holding the lock
+func (ao *Array) Snapshot() []Object {
+	ao.mu.RLock()
+	defer ao.mu.RUnlock()
+	return append([]Object{}, ao.Elements...)
+}
+
+// Index returns the element at i, negative indices count from the back.
+// It reports false if i is out of range.
+func (ao *Array) Index(i int64) (Object, bool) {
+	ao.mu.RLock()
+	defer ao.mu.RUnlock()
+
+	i, ok := ao.position(i)
+	if !ok {
+		return nil, false
+	}
+	return ao.Elements[i], true
+}
+
+// Modify replaces the element at i with the result of fn, while holding the lock so concurrent
+// changes are not lost. An *Error result is returned without being stored. It reports false if i is out of range.
+func (ao *Array) Modify(i int64, fn func(current Object) Object) (Object, bool) {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+
+	i, ok := ao.position(i)
+	if !ok {
+		return nil, false
+	}
+	val := fn(ao.Elements[i])
+	if _, isErr := val.(*Error); !isErr {
+		ao.Elements[i] = val
+	}
+	return val, true
+}
+
+// position resolves a negative index from the back, the caller holds the lock
+func (ao *Array) position(i int64) (int64, bool) {
+	if i < 0 {
+		i += int64(len(ao.Elements))
+	}
+	return i, i >= 0 && i < int64(len(ao.Elements))
+}
+
+func (ao *Array) Push(elements ...Object) {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+	ao.Elements = append(ao.Elements, elements...)
+}
+
+// Pop removes the last element and returns it. It reports false if the array is empty.
+func (ao *Array) Pop() (Object, bool) {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+
+	length := len(ao.Elements)
+	if length == 0 {
+		return nil, false
+	}
+	last := ao.Elements[length-1]
+	ao.Elements = ao.Elements[:length-1]
+	return last, true
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -122,6 +262,10 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // TODO: optimize performance
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
@@ -135,7 +279,10 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash is shared by reference like Array. Once a hash is visible to evaluated code,
+// its Pairs must only be accessed through the methods.
 type Hash struct {
+	mu    sync.RWMutex
 	Pairs map[HashKey]HashPair
 }
 
@@ -146,7 +293,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	var pairs []string
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Snapshot() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -157,6 +304,48 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+func (h *Hash) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.Pairs)
+}
+
+// Snapshot returns a copy of the pairs in no particular order, to iterate them without holding the lock
+func (h *Hash) Snapshot() []HashPair {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+// Modify binds key to the result of fn, which receives the current value or nil if the key is missing.
+// Like Array.Modify it holds the lock while calling fn and does not store an *Error result.
+func (h *Hash) Modify(key Object, hashKey HashKey, fn func(current Object) Object) Object {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var current Object
+	if pair, ok := h.Pairs[hashKey]; ok {
+		current = pair.Value
+	}
+	val := fn(current)
+	if _, isErr := val.(*Error); !isErr {
+		h.Pairs[hashKey] = HashPair{Key: key, Value: val}
+	}
+	return val
+}
+
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -173,7 +362,51 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue signal the enclosing loop, like ReturnValue does for functions
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// Range is a lazy integer sequence from Start (inclusive) to End (exclusive)
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Len returns the amount of values the range produces, capped at math.MaxInt64.
+// The distance is computed unsigned, it does not fit an int64 for ranges spanning both signs.
+func (r *Range) Len() int64 {
+	var distance, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		distance, step = uint64(r.End)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.End:
+		distance, step = uint64(r.Start)-uint64(r.End), -uint64(r.Step)
+	default:
+		return 0
+	}
+
+	n := (distance-1)/step + 1
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
 type Function struct {
+	Name       string // name of the first binding, empty for anonymous functions
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -245,3 +478,24 @@ type Quote struct {
 
 func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
 func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+// Module is an imported file. Only its exported bindings are reachable from the outside.
+type Module struct {
+	Name    string
+	Path    string // absolute path, also used as cache key
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("module %s (%s)", m.Name, m.Path) }
+
+// Timer is the handle of a pending `after` or `every` call
+type Timer struct {
+	ID       int64
+	Interval time.Duration
+	Repeat   bool
+	Cancel   func() bool // reports false if the timer already fired or was cancelled before
+}
+
+func (t *Timer) Type() ObjectType { return TIMER_OBJ }
+func (t *Timer) Inspect() string  { return fmt.Sprintf("timer#%d", t.ID) }
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"donkey/token"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +61,85 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	one1 := &Float{Value: 1.5}
+	one2 := &Float{Value: 1.5}
+	two1 := &Float{Value: 2.5}
+
+	if one1.HashKey() != one2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if one1.HashKey() == two1.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+
+	if one1.HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float and integer have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+		{1234567.5, "1234567.5"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong inspect output. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestFutureResolvesOnce(t *testing.T) {
+	f := NewFuture()
+	if f.Inspect() != "future(pending)" {
+		t.Errorf("wrong inspect output for pending future. got=%q", f.Inspect())
+	}
+
+	// waiting is only possible while a task is running that can resolve the future
+	TaskStarted()
+	go func() {
+		defer TaskDone()
+		f.Resolve(&Integer{Value: 1})
+	}()
+	result := f.Wait()
+	f.Resolve(&Integer{Value: 2})
+
+	if result.(*Integer).Value != 1 || f.Wait().(*Integer).Value != 1 {
+		t.Errorf("future result changed after it was resolved")
+	}
+	if f.Inspect() != "future(1)" {
+		t.Errorf("wrong inspect output for resolved future. got=%q", f.Inspect())
+	}
+}
+
+func TestErrorInspectTraceback(t *testing.T) {
+	err := &Error{
+		Message:  "division by zero: 1 / 0",
+		Location: &token.TokenLocation{Line: 1, Column: 27},
+		Stack: []Frame{
+			{Function: "divide", Location: &token.TokenLocation{Line: 2, Column: 26}},
+			{Function: "<anonymous>", Location: &token.TokenLocation{Line: 4, Column: 4}},
+			{Function: "run", Location: &token.TokenLocation{File: "main.dk", Line: 7, Column: 3}},
+		},
+	}
+
+	expected := "Runtime error: \u001b[31mLine: 1, col: 27 >> division by zero: 1 / 0\n" +
+		"Traceback (most recent call first):\n" +
+		"\tin divide, called at line 2, col 26\n" +
+		"\tin <anonymous>, called at line 4, col 4\n" +
+		"\tin run, called at main.dk:7:3"
+	if err.Inspect() != expected {
+		t.Errorf("wrong inspect output.\nwant=%q\ngot=%q", expected, err.Inspect())
+	}
+}
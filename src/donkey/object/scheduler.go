@@ -0,0 +1,199 @@
+package object
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Scheduler decides when async tasks run
+type Scheduler interface {
+	// Go starts the task next to the calling one
+	Go(task func())
+	// Yield gives other tasks a chance to run, the interpreter calls it before every statement
+	Yield()
+	// Block suspends the calling task until wake is closed
+	Block(wake <-chan struct{})
+	// Intn returns a random number in [0, n), for choices like the ready case of a select
+	Intn(n int) int
+}
+
+var scheduler atomic.Value
+
+func init() {
+	SetScheduler(NewGoroutineScheduler())
+}
+
+// SetScheduler replaces the scheduler for all tasks started afterwards.
+// It must only be called while no async tasks are running.
+func SetScheduler(s Scheduler) {
+	scheduler.Store(&s)
+}
+
+func currentScheduler() Scheduler {
+	return *scheduler.Load().(*Scheduler)
+}
+
+// Spawn starts the task with the current scheduler
+func Spawn(task func()) {
+	currentScheduler().Go(task)
+}
+
+// Yield lets the current scheduler switch to another task
+func Yield() {
+	currentScheduler().Yield()
+}
+
+// Park suspends the calling task with the current scheduler until wake is closed
+func Park(wake <-chan struct{}) {
+	currentScheduler().Block(wake)
+}
+
+// goroutineScheduler runs every task on its own goroutine, in parallel
+type goroutineScheduler struct{}
+
+func NewGoroutineScheduler() Scheduler {
+	return goroutineScheduler{}
+}
+
+func (goroutineScheduler) Go(task func())             { go task() }
+func (goroutineScheduler) Yield()                     {}
+func (goroutineScheduler) Block(wake <-chan struct{}) { <-wake }
+func (goroutineScheduler) Intn(n int) int             { return rand.Intn(n) }
+
+// deterministicScheduler runs one task at a time, like a single threaded event loop.
+// Tasks still live on their own goroutines, but only the one holding the baton runs.
+// At every yield the next task is picked by a seeded random generator,
+// so the same seed always produces the same interleaving.
+type deterministicScheduler struct {
+	mu      sync.Mutex
+	rand    *rand.Rand
+	current *task
+	waiting []*task       // started tasks that do not hold the baton
+	started chan struct{} // signals tasks started from outside, e.g. by timers, while all others are blocked
+}
+
+type task struct {
+	resume chan struct{}
+	wake   <-chan struct{} // nil if the task can run right away
+}
+
+func newTask() *task {
+	return &task{resume: make(chan struct{}, 1)}
+}
+
+// runnable reports whether the task is not blocked anymore. Requires the scheduler lock.
+func (t *task) runnable() bool {
+	if t.wake == nil {
+		return true
+	}
+	select {
+	case <-t.wake:
+		t.wake = nil
+		return true
+	default:
+		return false
+	}
+}
+
+// NewDeterministicScheduler creates a single threaded scheduler.
+// The goroutine creating it is treated as the main task holding the baton.
+func NewDeterministicScheduler(seed int64) Scheduler {
+	return &deterministicScheduler{
+		rand:    rand.New(rand.NewSource(seed)),
+		current: newTask(),
+		started: make(chan struct{}, 1),
+	}
+}
+
+func (s *deterministicScheduler) Go(fn func()) {
+	t := newTask()
+
+	s.mu.Lock()
+	s.waiting = append(s.waiting, t)
+	s.mu.Unlock()
+
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+
+	go func() {
+		<-t.resume
+		fn()
+		s.switchTask(nil)
+	}()
+}
+
+func (s *deterministicScheduler) Yield() {
+	s.switchTask(s.current)
+}
+
+func (s *deterministicScheduler) Block(wake <-chan struct{}) {
+	s.current.wake = wake
+	s.switchTask(s.current)
+}
+
+// Intn draws from the seeded generator, so choices of the tasks are reproduced along with the interleaving
+func (s *deterministicScheduler) Intn(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Intn(n)
+}
+
+// switchTask hands the baton to a random runnable task and waits until the calling task gets it back.
+// The calling task is nil if it finished.
+func (s *deterministicScheduler) switchTask(from *task) {
+	s.mu.Lock()
+	if from != nil {
+		s.waiting = append(s.waiting, from)
+	}
+	if len(s.waiting) == 0 {
+		s.current = nil
+		s.mu.Unlock()
+		return
+	}
+
+	next := s.pickRunnable()
+	s.current = next
+	s.mu.Unlock()
+
+	if next == from {
+		return
+	}
+	next.resume <- struct{}{}
+	if from != nil {
+		<-from.resume
+	}
+}
+
+// pickRunnable removes a random runnable task from the waiting ones.
+// If all of them are blocked, it waits until the first one is woken up or a new one is started.
+// Requires the scheduler lock, which is released while waiting.
+func (s *deterministicScheduler) pickRunnable() *task {
+	for {
+		var runnable []int
+		for i, t := range s.waiting {
+			if t.runnable() {
+				runnable = append(runnable, i)
+			}
+		}
+
+		if len(runnable) > 0 {
+			i := runnable[s.rand.Intn(len(runnable))]
+			t := s.waiting[i]
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			return t
+		}
+
+		// only code outside of the interpreter, e.g. a timer, can wake up a task now
+		cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.started)}}
+		for _, t := range s.waiting {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.wake)})
+		}
+		s.mu.Unlock()
+		reflect.Select(cases)
+		s.mu.Lock()
+	}
+}
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"donkey/token"
+	"fmt"
+)
+
+// ParseError describes a single syntax error. Rendering it, e.g. with colors, is up to the caller.
+type ParseError struct {
+	Start    token.TokenLocation // the range of the offending token
+	End      token.TokenLocation // position after the offending token
+	Expected token.TokenType     // only set if a specific token was expected
+	Actual   token.Token         // the offending token
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Line: %d, col: %d >> %s", e.Start.Line, e.Start.Column, e.Message)
+}
+
+func newParseError(tok token.Token, msg string) *ParseError {
+	return &ParseError{Start: tok.Location, End: tok.Location.End(), Actual: tok, Message: msg}
+}
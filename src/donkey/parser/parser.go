@@ -6,33 +6,65 @@ import (
 	"donkey/token"
 	"fmt"
 	"strconv"
+	"unicode/utf8"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = or +=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // * or %
 	PREFIX      // -X or !X
+	POWER       // **, binds tighter than prefix so -2 ** 2 == -4
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.LT_EQ:    LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.GT_EQ:    LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.BIT_OR:          BIT_OR,
+	token.BIT_XOR:         BIT_XOR,
+	token.BIT_AND:         BIT_AND,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
+}
+
+// Precedence returns how tightly the infix operator binds, LOWEST for tokens that are no infix operators
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 type (
@@ -43,11 +75,14 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []string
+	errors []*ParseError
 
 	curToken  token.Token
 	peekToken token.Token
 
+	loopDepth  int // how many loops enclose the current token, for break/continue validation
+	blockDepth int // how many blocks enclose the current token, for error recovery
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -55,7 +90,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:              l,
-		errors:         []string{},
+		errors:         []*ParseError{},
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
@@ -63,7 +98,9 @@ func New(l *lexer.Lexer) *Parser {
 	// PREFIX functions
 	p.registerPrefixFn(token.IDENT, p.parseIdentifier)
 	p.registerPrefixFn(token.INT, p.parseIntegerLiteral)
+	p.registerPrefixFn(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefixFn(token.STRING, p.parseStringLiteral)
+	p.registerPrefixFn(token.TEMPLATE_START, p.parseInterpolatedString)
 	p.registerPrefixFn(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefixFn(token.FALSE, p.parseBooleanLiteral)
 	p.registerPrefixFn(token.FUNCTION, func() ast.Expression { return p.parseFunctionLiteral(false) })
@@ -75,8 +112,14 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefixFn(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefixFn(token.LPAREN, p.parseGroupedExpression)
 
+	p.registerPrefixFn(token.ILLEGAL, p.parseIllegal)
+
 	p.registerPrefixFn(token.IF, p.parseIfExpression)
+	p.registerPrefixFn(token.FOR, p.parseForExpression)
 	p.registerPrefixFn(token.ASYNC, p.parseAsyncExpression)
+	p.registerPrefixFn(token.AWAIT, p.parseAwaitExpression)
+	p.registerPrefixFn(token.SELECT, p.parseSelectExpression)
+	p.registerPrefixFn(token.IMPORT, p.parseImportExpression)
 
 	// INFIX functions
 	p.registerInfixFn(token.PLUS, p.parseInfixExpression)
@@ -89,10 +132,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfixFn(token.LT_EQ, p.parseInfixExpression)
 	p.registerInfixFn(token.GT, p.parseInfixExpression)
 	p.registerInfixFn(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfixFn(token.PERCENT, p.parseInfixExpression)
+	p.registerInfixFn(token.POWER, p.parseInfixExpression)
+	p.registerInfixFn(token.AND, p.parseInfixExpression)
+	p.registerInfixFn(token.OR, p.parseInfixExpression)
+	p.registerInfixFn(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfixFn(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfixFn(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfixFn(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfixFn(token.SHIFT_RIGHT, p.parseInfixExpression)
+
+	p.registerInfixFn(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	p.registerInfixFn(token.LPAREN, p.parseCallExpression)
 	p.registerInfixFn(token.LBRACKET, p.parseIndexExpression)
-	//	p.registerInfixFn(token.DOT, p.parseIndexExpression)
+	p.registerInfixFn(token.DOT, p.parseMemberExpression)
 
 	// Read two token, so curToken & peekToken are both set
 	p.nextToken()
@@ -100,13 +158,18 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// comments are only of interest to tooling reading the tokens directly
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -117,15 +180,77 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
+// addParseError reports an error at the current token
 func (p *Parser) addParseError(msg string) {
-	lineColumnInfo := fmt.Sprintf("\u001b[31mLine: %d, col: %d >> ", p.curToken.Location.Line, p.curToken.Location.Column)
-	p.errors = append(p.errors, lineColumnInfo+msg+"\u001b[0m")
+	p.errors = append(p.errors, newParseError(p.curToken, msg))
 }
 
+// peekError reports an unexpected next token at that token's location
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.addParseError(msg)
+
+	err := newParseError(p.peekToken, msg)
+	err.Expected = t
+	p.errors = append(p.errors, err)
+}
+
+// synchronize skips the rest of a broken statement, so a single mistake does not cause follow-up errors.
+// It stops on the statement's semicolon or before a token that starts a new statement or closes the block.
+// Groups in braces, parentheses or brackets opened after the mistake are skipped as a whole.
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE, token.LPAREN, token.LBRACKET:
+			depth++
+		case token.RBRACE, token.RPAREN, token.RBRACKET:
+			if depth > 0 {
+				depth--
+			} else if p.curTokenIs(token.RBRACE) && p.blockDepth > 0 {
+				return
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.BREAK, token.CONTINUE, token.EOF:
+				return
+			case token.RBRACE:
+				if p.blockDepth > 0 {
+					return
+				}
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// parseStatements parses statements until the end token and drops every statement that
+// reported errors, so no partially parsed (nil) nodes end up in the AST
+func (p *Parser) parseStatements(end token.TokenType) []ast.Statement {
+	statements := []ast.Statement{}
+
+	for !p.curTokenIs(end) && !p.curTokenIs(token.EOF) {
+		errCount := len(p.errors)
+
+		stmt := p.parseStatement()
+		if len(p.errors) > errCount {
+			p.synchronize()
+			// the error was reported on the token closing the block, it must not be skipped
+			if p.curTokenIs(end) {
+				continue
+			}
+		} else if stmt != nil {
+			statements = append(statements, stmt)
+		}
+		p.nextToken()
+	}
+	return statements
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
@@ -139,17 +264,11 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.peekToken.Type)
 }
 
 func (p *Parser) registerPrefixFn(tokenType token.TokenType, fn prefixParseFn) {
@@ -173,9 +292,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	p.nextToken()
-
-	stmt.Value = p.parseExpression(LOWEST)
+	stmt.Value = p.parseNextExpression(LOWEST)
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -184,12 +301,60 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseExportStatement parses `export let name = value;`, which is only valid at the top level of a module
+func (p *Parser) parseExportStatement() *ast.LetStatement {
+	if p.blockDepth > 0 {
+		p.addParseError("export is only allowed at the top level")
+		return nil
+	}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	stmt := p.parseLetStatement()
+	if stmt == nil {
+		return nil
+	}
+	stmt.Exported = true
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
-	p.nextToken()
+	stmt.ReturnValue = p.parseNextExpression(LOWEST)
 
-	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.addParseError("break outside of loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.addParseError("continue outside of loop")
+		return nil
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -200,16 +365,19 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 func (p *Parser) parseBlockStatement(async bool) *ast.BlockStatement {
 	blckStmt := &ast.BlockStatement{Token: p.curToken, Async: async}
-	blckStmt.Statements = []ast.Statement{}
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			blckStmt.Statements = append(blckStmt.Statements, stmt)
-		}
-		p.nextToken()
+	p.blockDepth++
+	blckStmt.Statements = p.parseStatements(token.RBRACE)
+	p.blockDepth--
+
+	if p.curTokenIs(token.EOF) {
+		err := newParseError(p.curToken, "expected } to close the block, got EOF instead")
+		err.Expected = token.RBRACE
+		p.errors = append(p.errors, err)
+	} else {
+		blckStmt.RBrace = p.curToken
 	}
 	return blckStmt
 }
@@ -242,11 +410,62 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	defer untrace(trace("parseFloatLiteral"))
+
+	exp := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addParseError(msg)
+		return nil
+	}
+
+	exp.Value = value
+	return exp
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	defer untrace(trace("parseStringLiteral"))
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	defer untrace(trace("parseInterpolatedString"))
+
+	str := &ast.InterpolatedString{Token: p.curToken, Texts: []string{p.curToken.Literal}}
+
+	for {
+		p.nextToken()
+
+		if p.curTokenIs(token.TEMPLATE_PART) || p.curTokenIs(token.TEMPLATE_END) {
+			p.addParseError("empty expression in string interpolation")
+			return nil
+		}
+
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		str.Expressions = append(str.Expressions, exp)
+
+		switch {
+		case p.peekTokenIs(token.TEMPLATE_PART):
+			p.nextToken()
+			str.Texts = append(str.Texts, p.curToken.Literal)
+		case p.peekTokenIs(token.TEMPLATE_END):
+			p.nextToken()
+			str.Texts = append(str.Texts, p.curToken.Literal)
+			str.End = p.curToken
+			return str
+		default:
+			p.peekError(token.TEMPLATE_END)
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	defer untrace(trace("parseFunctionParameters"))
 
@@ -278,7 +497,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	elements, ok := p.parseExpressionList(token.RBRACKET)
+	if !ok {
+		return nil
+	}
+	array.Elements = elements
+	array.RBracket = p.curToken
 	return array
 }
 
@@ -287,15 +511,15 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBRACE) {
-		p.nextToken()
-		key := p.parseExpression(LOWEST)
-
-		if !p.expectPeek(token.COLON) {
+		key := p.parseNextExpression(LOWEST)
+		if key == nil || !p.expectPeek(token.COLON) {
 			return nil
 		}
 
-		p.nextToken()
-		value := p.parseExpression(LOWEST)
+		value := p.parseNextExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
 
 		hash.Pairs[key] = value
 
@@ -307,6 +531,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.RBrace = p.curToken
 
 	return hash
 }
@@ -326,7 +551,12 @@ func (p *Parser) parseFunctionLiteral(async bool) ast.Expression {
 		return nil
 	}
 
+	// break and continue must not reach through a function boundary
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement(async)
+	p.loopDepth = outerLoopDepth
+
 	return lit
 }
 
@@ -345,7 +575,10 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement(false)
+	p.loopDepth = outerLoopDepth
 
 	return lit
 }
@@ -355,9 +588,10 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 	exp := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
 
-	p.nextToken()
-
-	exp.Right = p.parseExpression(PREFIX)
+	exp.Right = p.parseNextExpression(PREFIX)
+	if exp.Right == nil {
+		return nil
+	}
 	return exp
 }
 
@@ -367,16 +601,59 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	exp := &ast.InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
 
 	precedence := p.curPrecedence()
+	// ** is right associative: 2 ** 3 ** 2 == 2 ** (3 ** 2)
+	if p.curTokenIs(token.POWER) {
+		precedence--
+	}
 
-	p.nextToken()
+	exp.Right = p.parseNextExpression(precedence)
+	if exp.Right == nil {
+		return nil
+	}
+	return exp
+}
+
+// parseAssignExpression is right associative, so `a = b = 1` assigns 1 to both
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	defer untrace(trace("parseAssignExpression"))
+
+	exp := &ast.AssignExpression{Token: p.curToken, Operator: p.curToken.Literal, Target: target}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.MemberExpression:
+	case nil:
+		return nil
+	default:
+		p.addParseError(fmt.Sprintf("cannot assign to %s", target.String()))
+		return nil
+	}
 
-	exp.Right = p.parseExpression(precedence)
+	exp.Value = p.parseNextExpression(ASSIGN - 1)
+	if exp.Value == nil {
+		return nil
+	}
 	return exp
 }
 
-func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s", t)
-	p.addParseError(msg)
+// parseIllegal reports ILLEGAL tokens at their location. Single characters are unknown input,
+// longer literals are diagnostics from the lexer e.g. "unterminated string".
+func (p *Parser) parseIllegal() ast.Expression {
+	if utf8.RuneCountInString(p.curToken.Literal) == 1 {
+		p.addParseError(fmt.Sprintf("illegal character %q", p.curToken.Literal))
+	} else {
+		p.addParseError(p.curToken.Literal)
+	}
+	return nil
+}
+
+// noPrefixParseFnError reports that tok can not start an expression, at tok's location
+func (p *Parser) noPrefixParseFnError(tok token.Token) {
+	msg := fmt.Sprintf("no prefix parse function for %s", tok.Type)
+	// the string continues where an operand was expected, like in "${1 + }"
+	if tok.Type == token.TEMPLATE_PART || tok.Type == token.TEMPLATE_END {
+		msg = "unterminated expression in string interpolation"
+	}
+	p.errors = append(p.errors, newParseError(tok, msg))
 }
 
 func (p *Parser) noInfixParseFnError(t token.TokenType) {
@@ -385,26 +662,82 @@ func (p *Parser) noInfixParseFnError(t token.TokenType) {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
-	p.nextToken()
-	exp := p.parseExpression(LOWEST)
-
-	if !p.expectPeek(token.RPAREN) {
+	exp := p.parseNextExpression(LOWEST)
+	if exp == nil || !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 	return exp
 }
 
+// parseIfExpression parses `if (cond) { } else if cond { } else { }`,
+// the parentheses around the condition are optional
 func (p *Parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
 
-	// TODO try () optional for if --> if expression consequence
+	exp.Condition = p.parseNextExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Consequence = p.parseBlockStatement(false)
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+
+			alternative, ok := p.parseIfExpression().(*ast.IfExpression)
+			if !ok {
+				return nil
+			}
+			exp.AlternativeIf = alternative
+			return exp
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Alternative = p.parseBlockStatement(false)
+	}
+
+	return exp
+}
+
+// parseForExpression parses both `for (x in iterable) {}`, `for (k, v in iterable) {}`
+// and the condition-only form `for (condition) {}`
+func (p *Parser) parseForExpression() ast.Expression {
+	defer untrace(trace("parseForExpression"))
+
+	exp := &ast.ForExpression{Token: p.curToken}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	p.nextToken()
+	first := p.parseNextExpression(LOWEST)
+
+	if ident, ok := first.(*ast.Identifier); ok && (p.peekTokenIs(token.IN) || p.peekTokenIs(token.COMMA)) {
+		exp.Value = ident
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			exp.Key = ident
+			exp.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
 
-	exp.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.IN) {
+			return nil
+		}
+
+		exp.Iterable = p.parseNextExpression(LOWEST)
+	} else {
+		exp.Condition = first
+	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
@@ -414,44 +747,142 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		return nil
 	}
 
-	exp.Consequence = p.parseBlockStatement(false)
+	p.loopDepth++
+	exp.Body = p.parseBlockStatement(false)
+	p.loopDepth--
 
-	if p.peekTokenIs(token.ELSE) {
+	return exp
+}
+
+func (p *Parser) parseAsyncExpression() ast.Expression {
+	if !p.expectPeek(token.FUNCTION) {
+		return nil
+	}
+
+	return p.parseFunctionLiteral(true)
+}
+
+func (p *Parser) parseAwaitExpression() ast.Expression {
+	exp := &ast.AwaitExpression{Token: p.curToken}
+
+	exp.Value = p.parseNextExpression(PREFIX)
+	if exp.Value == nil {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	exp := &ast.SelectExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 
-		if !p.expectPeek(token.LBRACE) {
+		switch {
+		case p.curTokenIs(token.CASE):
+			selectCase := p.parseSelectCase()
+			if selectCase == nil {
+				return nil
+			}
+			exp.Cases = append(exp.Cases, selectCase)
+
+		case p.curTokenIs(token.DEFAULT):
+			if exp.Default != nil {
+				p.addParseError("select has more than one default branch")
+				return nil
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			exp.Default = p.parseBlockStatement(false)
+
+		default:
+			p.addParseError(fmt.Sprintf("expected case or default in select, got %s instead", p.curToken.Type))
 			return nil
 		}
-		exp.Alternative = p.parseBlockStatement(false)
 	}
+	p.nextToken()
+	exp.RBrace = p.curToken
 
 	return exp
 }
 
-func (p *Parser) parseAsyncExpression() ast.Expression {
-	if !p.expectPeek(token.FUNCTION) {
+// parseSelectCase parses `case ch.recv() {}`, `case name = ch.recv() {}` or `case ch.send(value) {}`
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	selectCase := &ast.SelectCase{Token: p.curToken}
+
+	op := p.parseNextExpression(LOWEST)
+	if op == nil {
 		return nil
 	}
 
-	return p.parseFunctionLiteral(true)
+	if assign, ok := op.(*ast.AssignExpression); ok && assign.Operator == "=" {
+		if name, ok := assign.Target.(*ast.Identifier); ok {
+			selectCase.Name = name
+			op = assign.Value
+		}
+	}
+
+	call, ok := op.(*ast.CallExpression)
+	var member *ast.MemberExpression
+	if ok {
+		member, ok = call.Function.(*ast.MemberExpression)
+	}
+	switch {
+	case ok && member.Property.Value == "recv" && len(call.Arguments) == 0:
+	case ok && member.Property.Value == "send" && len(call.Arguments) == 1 && selectCase.Name == nil:
+		selectCase.Value = call.Arguments[0]
+	default:
+		msg := "select case must be `ch.recv()`, `name = ch.recv()` or `ch.send(value)`"
+		p.errors = append(p.errors, newParseError(selectCase.Token, msg))
+		return nil
+	}
+	selectCase.Channel = member.Object
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	selectCase.Body = p.parseBlockStatement(false)
+
+	return selectCase
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	args, ok := p.parseExpressionList(token.RPAREN)
+	if !ok {
+		return nil
+	}
+	exp.Arguments = args
+	exp.RParen = p.curToken
 	return exp
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	exp.Index = p.parseNextExpression(LOWEST)
+	if exp.Index == nil || !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.RBracket = p.curToken
+
+	return exp
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
 
-	if !p.expectPeek(token.RBRACKET) {
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	return exp
 }
 
@@ -460,7 +891,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 	prefixFn := p.prefixParseFns[p.curToken.Type]
 	if prefixFn == nil {
-		p.noPrefixParseFnError(p.curToken.Type)
+		p.noPrefixParseFnError(p.curToken)
 		return nil
 	}
 
@@ -474,32 +905,73 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 		p.nextToken()
 		leftExp = infixFn(leftExp)
+		// the error is reported already, continuing would only report follow-up errors
+		if leftExp == nil {
+			return nil
+		}
 	}
 	return leftExp
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
-	var list []ast.Expression
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
 
-	if p.peekTokenIs(end) {
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	exp.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.AS) {
 		p.nextToken()
-		return list
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		exp.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	return exp
+}
+
+// parseNextExpression advances to the next token and parses the expression starting there.
+// If that token can not start an expression the error is reported without consuming it,
+// so a closing `}` or `;` stays available for error recovery.
+func (p *Parser) parseNextExpression(precedence int) ast.Expression {
+	if p.prefixParseFns[p.peekToken.Type] == nil {
+		p.noPrefixParseFnError(p.peekToken)
+		return nil
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	return p.parseExpression(precedence)
+}
 
-	for p.peekTokenIs(token.COMMA) {
+// parseExpressionList parses comma separated expressions up to the end token.
+// It reports false if one of them is broken, the error is reported already.
+func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, bool) {
+	var list []ast.Expression
+
+	if p.peekTokenIs(end) {
 		p.nextToken()
+		return list, true
+	}
+
+	for {
+		exp := p.parseNextExpression(LOWEST)
+		if exp == nil {
+			return nil, false
+		}
+		list = append(list, exp)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
 	}
 
 	if !p.expectPeek(end) {
-		return nil
+		return nil, false
 	}
-
-	return list
+	return list, true
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
@@ -507,6 +979,9 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -514,27 +989,40 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
+// parseStatement returns nil if the statement could not be parsed.
+// The explicit nil checks avoid wrapping a nil pointer into a non-nil ast.Statement.
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.BREAK:
+		if stmt := p.parseBreakStatement(); stmt != nil {
+			return stmt
+		}
+	case token.CONTINUE:
+		if stmt := p.parseContinueStatement(); stmt != nil {
+			return stmt
+		}
+	case token.EXPORT:
+		if stmt := p.parseExportStatement(); stmt != nil {
+			return stmt
+		}
 	default:
-		return p.parseExpressionStatement()
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
 	}
+	return nil
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
-
-	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
-		p.nextToken()
-	}
+	program.Statements = p.parseStatements(token.EOF)
 	return program
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"donkey/ast"
 	"donkey/lexer"
+	"donkey/token"
+	"strings"
 	"testing"
 )
 
@@ -139,6 +141,42 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"2e3;", 2000},
+		{"2.5E-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d",
+				len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %f. got=%f", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -236,6 +274,74 @@ func TestParsingInfixExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 5;", "x", "+=", 5},
+		{"x -= y;", "x", "-=", "y"},
+		{"x *= true;", "x", "*=", true},
+		{"x /= 5;", "x", "/=", 5},
+		{`arr[0] = 5;`, "(arr[0])", "=", 5},
+		{`h["k"] = 5;`, `(h[k])`, "=", 5},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target is not %q. got=%q", tt.target, exp.Target.String())
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Value, tt.value) {
+			return
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []string{
+		"5 = 1;",
+		"f() = 1;",
+		"a + b = 1;",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -349,6 +455,62 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a = b = 1 + 2",
+			"a = b = (1 + 2)",
+		},
+		{
+			"a[1] += b * 2",
+			"(a[1]) += (b * 2)",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"-a.b * c.d(1)[0]",
+			"((-(a.b)) * ((c.d)(1)[0]))",
+		},
+		{
+			"h.name = 1 + 2",
+			"(h.name) = (1 + 2)",
+		},
+		{
+			"a == b && c != d || e",
+			"(((a == b) && (c != d)) || e)",
+		},
+		{
+			"a % b * c",
+			"((a % b) * c)",
+		},
+		{
+			"-2 ** 2",
+			"(-(2 ** 2))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"1 << 2 + 3",
+			"(1 << (2 + 3))",
+		},
+		{
+			"a >> 1 < b",
+			"((a >> 1) < b)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -509,6 +671,206 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestIfWithoutParentheses(t *testing.T) {
+	input := `if x < y { x } else { y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Alternative.Statements) != 1 {
+		t.Errorf("exp.Alternative.Statements does not contain 1 statements. got=%d\n",
+			len(exp.Alternative.Statements))
+	}
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if x > y { y } else if (x == y) { 0 } else { 1 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative was not nil. got=%+v", exp.Alternative)
+	}
+
+	second := exp.AlternativeIf
+	if second == nil {
+		t.Fatalf("exp.AlternativeIf is nil")
+	}
+	if !testInfixExpression(t, second.Condition, "x", ">", "y") {
+		return
+	}
+
+	third := second.AlternativeIf
+	if third == nil {
+		t.Fatalf("second.AlternativeIf is nil")
+	}
+	if !testInfixExpression(t, third.Condition, "x", "==", "y") {
+		return
+	}
+
+	if third.AlternativeIf != nil || third.Alternative == nil {
+		t.Fatalf("third if has wrong alternatives. got=%+v", third)
+	}
+
+	expected := "if (x < y) xelse if (x > y) yelse if (x == y) 0else 1"
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. expected=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestForInExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string
+		expectedValue string
+		iterable      string
+	}{
+		{`for (x in arr) { x; }`, "", "x", "arr"},
+		{`for (i, x in arr) { x; }`, "i", "x", "arr"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.ForExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T", stmt.Expression)
+		}
+
+		if tt.expectedKey == "" && exp.Key != nil {
+			t.Errorf("exp.Key was not nil. got=%+v", exp.Key)
+		}
+		if tt.expectedKey != "" && !testIdentifier(t, exp.Key, tt.expectedKey) {
+			return
+		}
+		if !testIdentifier(t, exp.Value, tt.expectedValue) {
+			return
+		}
+		if !testIdentifier(t, exp.Iterable, tt.iterable) {
+			return
+		}
+		if exp.Condition != nil {
+			t.Errorf("exp.Condition was not nil. got=%+v", exp.Condition)
+		}
+
+		if len(exp.Body.Statements) != 1 {
+			t.Errorf("body is not 1 statements. got=%d\n",
+				len(exp.Body.Statements))
+		}
+	}
+}
+
+func TestForConditionExpression(t *testing.T) {
+	input := `for (x < y) { if (x) { break; } continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Iterable != nil {
+		t.Errorf("exp.Iterable was not nil. got=%+v", exp.Iterable)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n",
+			len(exp.Body.Statements))
+	}
+
+	if _, ok := exp.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Fatalf("Statements[1] is not ast.ContinueStatement. got=%T",
+			exp.Body.Statements[1])
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []string{
+		"break;",
+		"continue;",
+		"for (x in arr) { fn() { break; } }",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -618,6 +980,97 @@ func TestAsyncFunctionParsing(t *testing.T) {
 	}
 }
 
+func TestAwaitExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"await f", "(await f)"},
+		{"await f(1)", "(await f(1))"},
+		{"await f + 1", "((await f) + 1)"},
+		{"let x = await all([a(), b()]);", "let x = (await all([a(), b()]));"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("await;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parser error for missing await operand")
+	}
+}
+
+func TestSelectExpressionParsing(t *testing.T) {
+	input := `select {
+		case msg = inbox.recv() { msg }
+		case out.send(1 + 2) { 2 }
+		case done.recv() { 3 }
+		default { 4 }
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SelectExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SelectExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Cases) != 3 {
+		t.Fatalf("select does not have 3 cases. got=%d", len(exp.Cases))
+	}
+	if !testIdentifier(t, exp.Cases[0].Name, "msg") || !testIdentifier(t, exp.Cases[0].Channel, "inbox") {
+		return
+	}
+	if exp.Cases[0].Value != nil || exp.Cases[2].Value != nil || exp.Cases[2].Name != nil {
+		t.Errorf("recv cases must not have a value")
+	}
+	if !testInfixExpression(t, exp.Cases[1].Value, 1, "+", 2) {
+		return
+	}
+	if exp.Default == nil || len(exp.Default.Statements) != 1 {
+		t.Errorf("select default branch not parsed")
+	}
+
+	expected := "select { case msg = inbox.recv() { msg } case out.send((1 + 2)) { 2 } case done.recv() { 3 } default { 4 } }"
+	if exp.String() != expected {
+		t.Errorf("wrong String(). want=%q, got=%q", expected, exp.String())
+	}
+
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"select { case ch.foo() { 1 } }", "select case must be `ch.recv()`, `name = ch.recv()` or `ch.send(value)`"},
+		{"select { case x = ch.send(1) { 1 } }", "select case must be `ch.recv()`, `name = ch.recv()` or `ch.send(value)`"},
+		{"select { default { 1 } default { 2 } }", "select has more than one default branch"},
+		{"select { 1 }", "expected case or default in select, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser error for %q", tt.input)
+		}
+		if p.Errors()[0].Message != tt.expectedError {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expectedError, p.Errors()[0].Message)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -729,6 +1182,121 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"total: ${a + b}"`, `"total: ${(a + b)}"`},
+		{`"${a}${b}"`, `"${a}${b}"`},
+		{`"nested ${"in ${x}"}!"`, `"nested ${"in ${x}"}!"`},
+		{`"call ${add(1, 2)} and ${arr[0]}"`, `"call ${add(1, 2)} and ${(arr[0])}"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+
+		if len(str.Texts) != len(str.Expressions)+1 {
+			t.Errorf("wrong number of texts. got=%d for %d expressions", len(str.Texts), len(str.Expressions))
+		}
+
+		if str.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, str.String())
+		}
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc ${1 + }"`, "Line: 1, col: 12 >> unterminated expression in string interpolation"},
+		{`"abc ${1 + } def"`, "Line: 1, col: 12 >> unterminated expression in string interpolation"},
+		{`"${-} and ${x}"`, "Line: 1, col: 5 >> unterminated expression in string interpolation"},
+		{`"abc ${}"`, "Line: 1, col: 8 >> empty expression in string interpolation"},
+		{"let a = 1;\n\"x ${a b}\"", "Line: 2, col: 8 >> expected next token to be TEMPLATE_END, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+
+		if !strings.Contains(errors[0].Error(), tt.expected) {
+			t.Errorf("wrong parser error. expected to contain %q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParsingSkipsCommentTokens(t *testing.T) {
+	input := `
+// answer
+let a = /* inline */ 42; // trailing
+a /* end */`
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	if !testLetStatement(t, program.Statements[0], "a") {
+		return
+	}
+	testIntegerLiteral(t, program.Statements[0].(*ast.LetStatement).Value, 42)
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = "open;`, "Line: 1, col: 9 >> unterminated string"},
+		{"let a = 1;\nlet b = `open;", "Line: 2, col: 9 >> unterminated string"},
+		{`"bad \q";`, "Line: 1, col: 1 >> invalid escape sequence \\q in string"},
+		{`let a = @;`, "Line: 1, col: 9 >> illegal character \"@\""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+
+		if !strings.Contains(errors[0].Error(), tt.expected) {
+			t.Errorf("wrong parser error. expected to contain %q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -817,6 +1385,128 @@ func TestParsingIndexTestExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingMemberExpressions(t *testing.T) {
+	input := "myHash.name"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	memberExp, ok := stmt.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, memberExp.Object, "myHash") {
+		return
+	}
+
+	if !testIdentifier(t, memberExp.Property, "name") {
+		return
+	}
+
+	p = New(lexer.New("myHash.1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parser error for non identifier member")
+	}
+}
+
+func TestImportExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+	}{
+		{`import "lib.dk"`, "lib.dk", ""},
+		{`import "path/to/lib.dk" as lib;`, "path/to/lib.dk", "lib"},
+		{`let m = import "m.dk"; m`, "m.dk", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var exp ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			exp = stmt.Expression
+		case *ast.LetStatement:
+			exp = stmt.Value
+		}
+
+		imp, ok := exp.(*ast.ImportExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.ImportExpression. got=%T", exp)
+		}
+		if imp.Path.Value != tt.expectedPath {
+			t.Errorf("imp.Path wrong. want=%q, got=%q", tt.expectedPath, imp.Path.Value)
+		}
+		if tt.expectedAlias == "" {
+			if imp.Alias != nil {
+				t.Errorf("imp.Alias should be nil. got=%q", imp.Alias.Value)
+			}
+		} else if !testIdentifier(t, imp.Alias, tt.expectedAlias) {
+			return
+		}
+	}
+
+	for _, input := range []string{`import lib`, `import "lib.dk" as`, `import "lib.dk" as "lib"`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q", input)
+		}
+	}
+}
+
+func TestExportStatementParsing(t *testing.T) {
+	l := lexer.New("export let x = 5; let y = 1;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	exported := program.Statements[0].(*ast.LetStatement)
+	if !exported.Exported || exported.String() != "export let x = 5;" {
+		t.Errorf("first let should be exported. got=%q", exported.String())
+	}
+	if program.Statements[1].(*ast.LetStatement).Exported {
+		t.Errorf("second let should not be exported")
+	}
+
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"export 5", "expected next token to be LET, got INT instead"},
+		{"fn() { export let x = 1; }", "export is only allowed at the top level"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser error for %q", tt.input)
+		}
+		if p.Errors()[0].Message != tt.expectedError {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expectedError, p.Errors()[0].Message)
+		}
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -1171,6 +1861,88 @@ func TestMacroLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     int
+		expectedStatements int
+	}{
+		{"let = 5; let y = 1; y;", 1, 2},
+		{"let a = (1 + 2; let b = 3;", 1, 1},
+		{"let a = ; let b = 2; b", 1, 2},
+		{"fn() { let = 1; x }; 5", 1, 1},
+		{"let f = fn() { return ; }; let g = fn() { 1 + }; 3", 2, 1},
+		{"1 +; 2 *; 3", 2, 1},
+		{"fn() { x", 1, 0},
+		{"let x 5; let y = 6;", 1, 1},
+		// an unclosed `(` before a block is a single mistake, the block is skipped as a whole
+		{"for (x in [1,2] { break; }; 4", 1, 1},
+		{"if (x > 1 { let y = 2; y }; 5", 1, 1},
+		{"fn() { if (a { return 1; }; 2 }; 3", 1, 1},
+		{"let a = [1, (2 { 3 }]; let b = 1;", 1, 1},
+		{"f(1 + { let a = 1; }); 6", 1, 1},
+		{"let h = {1: }; 2", 1, 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != tt.expectedErrors {
+			t.Errorf("%q: wrong number of errors. expected=%d, got=%d (%v)",
+				tt.input, tt.expectedErrors, len(p.Errors()), p.Errors())
+		}
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("%q: wrong number of statements. expected=%d, got=%d",
+				tt.input, tt.expectedStatements, len(program.Statements))
+		}
+
+		for _, stmt := range program.Statements {
+			if stmt == nil {
+				t.Fatalf("%q: nil statement in program", tt.input)
+			}
+			if es, ok := stmt.(*ast.ExpressionStatement); ok && es.Expression == nil {
+				t.Fatalf("%q: nil expression in program", tt.input)
+			}
+			// must not panic on partially parsed nodes
+			_ = stmt.String()
+		}
+	}
+}
+
+func TestStructuredParseError(t *testing.T) {
+	l := lexer.New("let x 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error. got=%d", len(errors))
+	}
+
+	err := errors[0]
+	if err.Expected != token.ASSIGN {
+		t.Errorf("err.Expected wrong. expected=%q, got=%q", token.ASSIGN, err.Expected)
+	}
+	if err.Actual.Type != token.INT || err.Actual.Literal != "5" {
+		t.Errorf("err.Actual wrong. got=%+v", err.Actual)
+	}
+	if err.Start != (token.TokenLocation{Line: 1, Column: 7, Offset: 6, EndLine: 1, EndColumn: 8, EndOffset: 7}) {
+		t.Errorf("err.Start wrong. got=%+v", err.Start)
+	}
+	if err.End != (token.TokenLocation{Line: 1, Column: 8, Offset: 7, EndLine: 1, EndColumn: 8, EndOffset: 7}) {
+		t.Errorf("err.End wrong. got=%+v", err.End)
+	}
+	if err.Message != "expected next token to be =, got INT instead" {
+		t.Errorf("err.Message wrong. got=%q", err.Message)
+	}
+	if strings.Contains(err.Error(), "\u001b") {
+		t.Errorf("err.Error() must not contain color codes. got=%q", err.Error())
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -1183,3 +1955,45 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foo;", "foo"},
+		{"let x = 1 + 2;", "let x = 1 + 2"},
+		{"return a * b;", "return a * b"},
+		{"-a + b * c", "-a + b * c"},
+		{"add(1, [2, 3])", "add(1, [2, 3])"},
+		{"arr[1 + 1]", "arr[1 + 1]"},
+		{"obj.len()", "obj.len()"},
+		{`{"a": 1}`, `{"a": 1}`},
+		{`"total: ${a + b}!"`, `"total: ${a + b}!"`},
+		{"fn(x) {\n  x\n}", "fn(x) {\n  x\n}"},
+		{"if (a) { b } else if (c) { d }", "if (a) { b } else if (c) { d }"},
+		{"for (x in xs) { x }", "for (x in xs) { x }"},
+		{"x += 1", "x += 1"},
+		{"await f()", "await f()"},
+		{`import "lib.dk" as lib`, `import "lib.dk" as lib`},
+		{"select { default { 1 } }", "select { default { 1 } }"},
+		{"a; b", "a; b"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var node ast.Node = program
+		if len(program.Statements) == 1 {
+			node = program.Statements[0]
+		}
+
+		span := node.Span()
+		if got := tt.input[span.Offset:span.EndOffset]; got != tt.expected {
+			t.Errorf("wrong span for %q. want=%q, got=%q (%+v)", tt.input, tt.expected, got, span)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package repl
+
+import (
+	"donkey/ast"
+	"donkey/diagnostics"
+	"donkey/evaluator"
+	"donkey/lexer"
+	"donkey/object"
+	"donkey/token"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lastResult is bound to the result of the last evaluated input
+const lastResult = "_"
+
+const commandHelp = `:env               list the bindings and macros of the session
+:reset             start over with an empty session
+:load file.dk      evaluate the file in the session
+:tokens expr       show the tokens of the input
+:ast expr          show the syntax tree of the input
+:expand expr       show the input after macro expansion
+:type expr         evaluate the input and show the type of its result
+:time expr         evaluate the input and show how long it took
+:paste             enter input until :end, :cancel discards it
+`
+
+// session holds the bindings that live across inputs
+type session struct {
+	env      *object.Environment
+	macroEnv *object.Environment
+	entries  int // the number of evaluated inputs
+}
+
+func newSession() *session {
+	return &session{env: object.NewEnvironment(), macroEnv: object.NewEnvironment()}
+}
+
+// entryName names the next input like a file, e.g. `<repl:3>`, and keeps its code.
+// Functions defined by the input still point to their code when they fail in a later one.
+func (s *session) entryName(source string) string {
+	s.entries++
+	name := fmt.Sprintf("<repl:%d>", s.entries)
+	diagnostics.RegisterSource(name, source)
+	return name
+}
+
+// commands are input lines starting with a colon, followed by an optional argument
+var commands map[string]func(c *config, out io.Writer, s *session, arg string)
+
+func init() {
+	commands = map[string]func(c *config, out io.Writer, s *session, arg string){
+		"help":   helpCommand,
+		"env":    envCommand,
+		"reset":  resetCommand,
+		"load":   loadCommand,
+		"tokens": tokensCommand,
+		"ast":    astCommand,
+		"expand": expandCommand,
+		"type":   typeCommand,
+		"time":   timeCommand,
+	}
+}
+
+// parseCommand splits `:name argument` into its parts. It reports false if the input is not a command.
+func parseCommand(input string) (string, string, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, ":") {
+		return "", "", false
+	}
+
+	name, arg, _ := strings.Cut(input[1:], " ")
+	if before, after, found := strings.Cut(name, "\n"); found {
+		// the argument can start on the next line
+		name, arg = before, after+" "+arg
+	}
+	return name, strings.TrimSpace(arg), true
+}
+
+func (c *config) runCommand(out io.Writer, s *session, name, arg string) {
+	command, ok := commands[name]
+	if !ok {
+		c.printError(out, fmt.Sprintf("unknown command :%s, type :help for the list of commands", name))
+		return
+	}
+	command(c, out, s, arg)
+}
+
+func helpCommand(c *config, out io.Writer, s *session, arg string) {
+	io.WriteString(out, commandHelp)
+}
+
+func envCommand(c *config, out io.Writer, s *session, arg string) {
+	writeBindings(out, s.env)
+	if names := s.macroEnv.Names(); len(names) > 0 {
+		io.WriteString(out, "// macros\n")
+		writeBindings(out, s.macroEnv)
+	}
+}
+
+// writeBindings prints every name with its value, functions are shortened to their signature
+func writeBindings(out io.Writer, env *object.Environment) {
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		inspected := value.Inspect()
+		if first, _, multiLine := strings.Cut(inspected, "\n"); multiLine {
+			inspected = first + " ... }"
+		}
+		fmt.Fprintf(out, "%s = %s\n", name, inspected)
+	}
+}
+
+func resetCommand(c *config, out io.Writer, s *session, arg string) {
+	// inputs keep counting, the names of earlier ones must not be reused
+	entries := s.entries
+	*s = *newSession()
+	s.entries = entries
+	io.WriteString(out, "session reset\n")
+}
+
+func loadCommand(c *config, out io.Writer, s *session, arg string) {
+	if arg == "" {
+		c.printError(out, "usage: :load file.dk")
+		return
+	}
+
+	source, err := os.ReadFile(arg)
+	if err != nil {
+		c.printError(out, err.Error())
+		return
+	}
+
+	if _, ok := c.run(out, string(source), arg, s); ok {
+		fmt.Fprintf(out, "loaded %s\n", arg)
+	}
+}
+
+func tokensCommand(c *config, out io.Writer, s *session, arg string) {
+	l := lexer.New(arg, lexer.WithComments())
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(out, "%d:%d\t%s\t%q\n", tok.Location.Line, tok.Location.Column, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+func astCommand(c *config, out io.Writer, s *session, arg string) {
+	program, ok := c.parse(out, arg, "")
+	if !ok {
+		return
+	}
+	io.WriteString(out, formatTree(program))
+}
+
+// expandCommand expands the macros of the input. Macros defined by the input are only visible to the input itself.
+func expandCommand(c *config, out io.Writer, s *session, arg string) {
+	program, ok := c.parse(out, arg, "")
+	if !ok {
+		return
+	}
+
+	macroEnv := object.NewEnclosedEnvironment(s.macroEnv)
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		c.printRuntimeError(out, arg, err)
+		return
+	}
+	io.WriteString(out, expanded.String()+"\n")
+}
+
+func typeCommand(c *config, out io.Writer, s *session, arg string) {
+	if result, ok := c.run(out, arg, "", s); ok && result != nil {
+		io.WriteString(out, string(result.Type())+"\n")
+	}
+}
+
+func timeCommand(c *config, out io.Writer, s *session, arg string) {
+	start := time.Now()
+	c.evaluate(out, arg, s)
+	fmt.Fprintf(out, "took %s\n", time.Since(start))
+}
+
+// formatTree prints the node and its children indented by depth, with their fields and source ranges
+func formatTree(node ast.Node) string {
+	var out strings.Builder
+	writeTree(&out, "", reflect.ValueOf(node), 0)
+	return out.String()
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+func writeTree(out *strings.Builder, label string, v reflect.Value, depth int) {
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.IsNil() {
+		return
+	}
+
+	var details []string
+	// select cases are no nodes, but have a span as well
+	if node, ok := v.Interface().(interface{ Span() token.TokenLocation }); ok {
+		span := node.Span()
+		details = append(details, fmt.Sprintf("%d:%d-%d:%d", span.Line, span.Column, span.EndLine, span.EndColumn))
+	}
+
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	type child struct {
+		label string
+		value reflect.Value
+	}
+	var children []child
+
+	for i := 0; i < v.NumField(); i++ {
+		name, field := v.Type().Field(i).Name, v.Field(i)
+
+		switch {
+		case !v.Type().Field(i).IsExported() || field.Type() == tokenType:
+			continue
+		case field.Kind() == reflect.Bool:
+			if field.Bool() {
+				details = append(details, name)
+			}
+		case field.Kind() == reflect.String:
+			details = append(details, fmt.Sprintf("%s=%q", name, field.String()))
+		case field.Kind() == reflect.Int64 || field.Kind() == reflect.Float64:
+			details = append(details, fmt.Sprintf("%s=%v", name, field.Interface()))
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			details = append(details, fmt.Sprintf("%s=%q", name, field.Interface()))
+		case field.Kind() == reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				children = append(children, child{name, field.Index(j)})
+			}
+		case field.Kind() == reflect.Map:
+			// hash literal pairs, in source order
+			keys := field.MapKeys()
+			sort.Slice(keys, func(a, b int) bool {
+				return keys[a].Interface().(ast.Node).Span().Offset < keys[b].Interface().(ast.Node).Span().Offset
+			})
+			for _, key := range keys {
+				children = append(children, child{name + " key", key}, child{name + " value", field.MapIndex(key)})
+			}
+		default:
+			children = append(children, child{name, field})
+		}
+	}
+
+	out.WriteString(strings.Repeat("  ", depth))
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+	out.WriteString(v.Type().Name())
+	if len(details) > 0 {
+		out.WriteString(" " + strings.Join(details, " "))
+	}
+	out.WriteString("\n")
+
+	for _, c := range children {
+		writeTree(out, c.label, c.value, depth+1)
+	}
+}
@@ -0,0 +1,374 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned for Ctrl-C, which discards the unfinished input
+var errInterrupted = errors.New("interrupted")
+
+// keys the line editor handles
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// lineEditor reads lines key by key from a raw terminal, with emacs style editing,
+// history browsing, reverse search (Ctrl-R) and tab completion
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func(prefix string) []string // returns the names starting with prefix
+
+	prompt string
+	line   []rune
+	cursor int
+
+	browsing int    // index of the shown history entry, len(entries) for the new line
+	pending  string // the new line while browsing the history
+}
+
+func newLineEditor(in io.Reader, out io.Writer, history *history, complete func(string) []string) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, history: history, complete: complete}
+}
+
+// ReadLine shows the prompt and returns the entered line. It returns io.EOF for Ctrl-D
+// on an empty line and errInterrupted for Ctrl-C.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	e.prompt, e.line, e.cursor = prompt, nil, 0
+	e.browsing, e.pending = len(e.history.entries), ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyLineFeed:
+			return e.submit(), nil
+		case keyCtrlC:
+			e.write("^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				e.write("\r\n")
+				return "", io.EOF
+			}
+			e.deleteRange(e.cursor, e.cursor+1)
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlB:
+			e.cursor = max(e.cursor-1, 0)
+		case keyCtrlF:
+			e.cursor = min(e.cursor+1, len(e.line))
+		case keyBackspace, keyDelete:
+			e.deleteRange(e.cursor-1, e.cursor)
+		case keyCtrlK:
+			e.deleteRange(e.cursor, len(e.line))
+		case keyCtrlU:
+			e.deleteRange(0, e.cursor)
+		case keyCtrlW:
+			e.deleteRange(e.wordStart(unicode.IsSpace), e.cursor)
+		case keyCtrlL:
+			e.write("\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.browseHistory(-1)
+		case keyCtrlN:
+			e.browseHistory(1)
+		case keyCtrlR:
+			submit, err := e.reverseSearch()
+			if err != nil {
+				return "", err
+			}
+			if submit {
+				return e.submit(), nil
+			}
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			if err := e.handleEscape(); err != nil {
+				return "", err
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert(r)
+			}
+		}
+		e.refresh()
+	}
+}
+
+func (e *lineEditor) submit() string {
+	e.cursor = len(e.line)
+	e.refresh()
+	e.write("\r\n")
+
+	line := string(e.line)
+	e.history.add(line)
+	return line
+}
+
+// handleEscape reads the rest of an escape sequence like `ESC [ A` for the arrow keys
+func (e *lineEditor) handleEscape() error {
+	kind, _, err := e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	if kind != '[' && kind != 'O' {
+		return nil
+	}
+
+	var seq strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		seq.WriteRune(r)
+		// parameters are digits and `;`, any other character ends the sequence
+		if !unicode.IsDigit(r) && r != ';' {
+			break
+		}
+	}
+
+	switch seq.String() {
+	case "A":
+		e.browseHistory(-1)
+	case "B":
+		e.browseHistory(1)
+	case "C":
+		e.cursor = min(e.cursor+1, len(e.line))
+	case "D":
+		e.cursor = max(e.cursor-1, 0)
+	case "H", "1~", "7~":
+		e.cursor = 0
+	case "F", "4~", "8~":
+		e.cursor = len(e.line)
+	case "3~":
+		e.deleteRange(e.cursor, e.cursor+1)
+	}
+	return nil
+}
+
+func (e *lineEditor) insert(runes ...rune) {
+	line := append([]rune{}, e.line[:e.cursor]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.cursor:]...)
+	e.cursor += len(runes)
+}
+
+// deleteRange removes the runes from start up to end, both are clamped to the line
+func (e *lineEditor) deleteRange(start, end int) {
+	start, end = max(start, 0), min(end, len(e.line))
+	if start >= end {
+		return
+	}
+
+	e.line = append(e.line[:start:start], e.line[end:]...)
+	if e.cursor > end {
+		e.cursor -= end - start
+	} else if e.cursor > start {
+		e.cursor = start
+	}
+}
+
+// wordStart returns where the word before the cursor starts, words are separated by runes matching isSeparator
+func (e *lineEditor) wordStart(isSeparator func(rune) bool) int {
+	start := e.cursor
+	for start > 0 && isSeparator(e.line[start-1]) {
+		start--
+	}
+	for start > 0 && !isSeparator(e.line[start-1]) {
+		start--
+	}
+	return start
+}
+
+func (e *lineEditor) setLine(line string) {
+	e.line = []rune(line)
+	e.cursor = len(e.line)
+}
+
+// browseHistory shows an older (-1) or newer (1) history entry
+func (e *lineEditor) browseHistory(delta int) {
+	idx := e.browsing + delta
+	if idx < 0 || idx > len(e.history.entries) {
+		return
+	}
+
+	if e.browsing == len(e.history.entries) {
+		e.pending = string(e.line)
+	}
+	e.browsing = idx
+
+	if idx == len(e.history.entries) {
+		e.setLine(e.pending)
+	} else {
+		e.setLine(e.history.entries[idx])
+	}
+}
+
+// reverseSearch finds the newest history entry containing the typed query, Ctrl-R again finds older ones.
+// Enter submits the match, Ctrl-G or Ctrl-C restore the line and any other key keeps the match for editing.
+func (e *lineEditor) reverseSearch() (bool, error) {
+	original := string(e.line)
+	var query []rune
+	match := -1
+
+	for {
+		status := "reverse-i-search"
+		if match < 0 && len(query) > 0 {
+			status = "failing reverse-i-search"
+		}
+		found := ""
+		if match >= 0 {
+			found = e.history.entries[match]
+		}
+		e.write(fmt.Sprintf("\r(%s)`%s': %s\x1b[K", status, string(query), found))
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case r == keyCtrlR:
+			if match > 0 {
+				if older := e.history.search(string(query), match-1); older >= 0 {
+					match = older
+				}
+			}
+		case r == keyBackspace || r == keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = e.history.search(string(query), len(e.history.entries)-1)
+			}
+		case r == keyCtrlG || r == keyCtrlC:
+			e.setLine(original)
+			return false, nil
+		case r == keyEnter || r == keyLineFeed:
+			if match >= 0 {
+				e.setLine(found)
+			}
+			return true, nil
+		case unicode.IsPrint(r):
+			query = append(query, r)
+			from := len(e.history.entries) - 1
+			if match >= 0 {
+				from = match
+			}
+			if newer := e.history.search(string(query), from); newer >= 0 {
+				match = newer
+			} else {
+				match = -1
+			}
+		default:
+			if match >= 0 {
+				e.setLine(found)
+			}
+			e.in.UnreadRune()
+			return false, nil
+		}
+	}
+}
+
+// completeWord completes the identifier before the cursor. If several names match,
+// their common prefix is inserted, or they are listed if there is nothing to insert.
+func (e *lineEditor) completeWord() {
+	start := e.wordStart(func(r rune) bool { return !isIdentifierRune(r) })
+	if start < e.cursor && !isIdentifierRune(e.line[e.cursor-1]) {
+		start = e.cursor
+	}
+	prefix := string(e.line[start:e.cursor])
+	if prefix == "" {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+
+	common := []rune(commonPrefix(candidates))
+	if len(common) > e.cursor-start {
+		e.insert(common[e.cursor-start:]...)
+		return
+	}
+	if len(candidates) > 1 {
+		e.write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+	}
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		w := []rune(word)
+		n := 0
+		for n < len(prefix) && n < len(w) && prefix[n] == w[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+// refresh redraws the prompt and the line and moves the terminal cursor to the edit position
+func (e *lineEditor) refresh() {
+	e.write("\r" + e.prompt + string(e.line) + "\x1b[K")
+	if back := len(e.line) - e.cursor; back > 0 {
+		e.write(fmt.Sprintf("\x1b[%dD", back))
+	}
+}
+
+func (e *lineEditor) write(s string) {
+	io.WriteString(e.out, s)
+}
+
+// completions returns the sorted, distinct names starting with prefix
+func completions(prefix string, names ...[]string) []string {
+	seen := map[string]bool{}
+	var matches []string
+	for _, list := range names {
+		for _, name := range list {
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
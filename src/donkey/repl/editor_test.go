@@ -0,0 +1,130 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEditor(keys string, entries ...string) *lineEditor {
+	complete := func(prefix string) []string {
+		return completions(prefix, []string{"let", "len", "last"}, []string{"length", "let"})
+	}
+	return newLineEditor(strings.NewReader(keys), &bytes.Buffer{}, &history{entries: entries}, complete)
+}
+
+func TestLineEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"1 + 2\r", "1 + 2"},
+		{"1 + 2\x7f3\r", "1 + 3"},
+		{"2 + 3\x01\x1b[3~4\r", "4 + 3"},
+		{"a + b\x1b[D\x1b[D\x1b[D\x0b- c\r", "a - c"},
+		{"hello world\x17there\r", "hello there"},
+		{"abc\x02\x02\x15x\x05y\r", "xbcy"},
+		{"ab\x01\x04\r", "b"},
+		{"ab\x1b[H\x1b[Cc\x1b[Fd\r", "acbd"},
+		{"let x = 1; la\t\r", "let x = 1; last"},
+		{"le\t\r", "le"},
+		{"lengt\t\r", "length"},
+		{"x.y\t\r", "x.y"},
+		{"ünï\x7fö\r", "ünö"},
+	}
+
+	for _, tt := range tests {
+		line, err := testEditor(tt.keys).ReadLine("> ")
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. want=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorCompletionList(t *testing.T) {
+	e := testEditor("le\t\r")
+	e.ReadLine("> ")
+
+	if out := e.out.(*bytes.Buffer).String(); !strings.Contains(out, "len  length  let") {
+		t.Errorf("candidates not listed. got=%q", out)
+	}
+}
+
+func TestLineEditorInterrupts(t *testing.T) {
+	if _, err := testEditor("1 +\x03").ReadLine("> "); !errors.Is(err, errInterrupted) {
+		t.Errorf("Ctrl-C did not interrupt. got=%v", err)
+	}
+	if _, err := testEditor("\x04").ReadLine("> "); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line did not end the input. got=%v", err)
+	}
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x1b[A\r", "third"},
+		{"\x1b[A\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"\x1b[A\x1b[A\x1b[B\r", "third"},
+		{"new\x10\x0e\r", "new"},
+		{"\x10!\r", "third!"},
+		{"\x12sec\r", "second"},
+		{"\x12ir\r", "third"},
+		{"\x12ir\x12\r", "first"},
+		{"\x12ir\x12\x12\r", "first"},
+		{"\x12ir\x7f\x7fs\r", "second"},
+		{"\x12sec\x05!\r", "second!"},
+		{"keep\x12sec\x07\r", "keep"},
+		{"\x12nothing\r", ""},
+	}
+
+	for _, tt := range tests {
+		e := testEditor(tt.keys, "first", "second", "third")
+		line, err := e.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("%q: wrong line. want=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "donkey", "history")
+
+	h := loadHistory(path)
+	for _, line := range []string{"let a = 1;", "", "a + 1", "a + 1", "a * 2"} {
+		h.add(line)
+	}
+
+	loaded := loadHistory(path)
+	expected := []string{"let a = 1;", "a + 1", "a * 2"}
+	if strings.Join(loaded.entries, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong history. want=%q, got=%q", expected, loaded.entries)
+	}
+
+	lines := make([]string, historySize+10)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", i+1)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded = loadHistory(path)
+	if len(loaded.entries) != historySize || loaded.entries[0] != lines[10] {
+		t.Errorf("history was not truncated. got %d entries", len(loaded.entries))
+	}
+	if content, _ := os.ReadFile(path); strings.Count(string(content), "\n") != historySize {
+		t.Errorf("history file was not truncated")
+	}
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"donkey/constants"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historySize is the amount of entered lines that are kept
+const historySize = 1000
+
+// history holds the entered lines, oldest first. If it has a path, every line is appended to that file.
+type history struct {
+	entries []string
+	path    string
+}
+
+// DefaultHistoryPath returns the history file in the user's config dir, or "" if there is none
+func DefaultHistoryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, constants.LangName, "history")
+}
+
+// loadHistory reads the history file. The history is kept in memory only if path is empty.
+// History is a convenience, so a missing or unwritable file is not an error.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+
+	if len(h.entries) > historySize {
+		h.entries = h.entries[len(h.entries)-historySize:]
+		os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+	}
+	return h
+}
+
+// add appends the line, unless it is blank or repeats the previous one
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == line) {
+		return
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > historySize {
+		h.entries = h.entries[1:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
+
+// search returns the index of the newest entry at or before from that contains the query, or -1
+func (h *history) search(query string, from int) int {
+	for i := min(from, len(h.entries)-1); i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,150 @@
+package repl
+
+import (
+	"bufio"
+	"donkey/constants"
+	"donkey/lexer"
+	"donkey/token"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Lines the REPL treats as commands while reading input
+const (
+	cancelCommand = ":cancel" // discards the unfinished input
+	pasteCommand  = ":paste"  // reads everything up to pasteEnd as a single input
+	pasteEnd      = ":end"
+)
+
+// lineReader reads one line of input after showing the prompt
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scannerReader reads lines from input that is not a terminal, e.g. a pipe
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// terminalReader reads lines with the line editor, switching the terminal to raw mode only while reading,
+// so evaluated code sees the terminal as usual
+type terminalReader struct {
+	fd     uintptr
+	editor *lineEditor
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	return r.editor.ReadLine(prompt)
+}
+
+// readInput reads lines until they form a complete input, showing the continuation prompt in between.
+// Ctrl-C discards the unfinished input. It reports false once the input is exhausted.
+func (c *config) readInput(reader lineReader, out io.Writer) (string, bool) {
+	var lines []string
+
+	for {
+		prompt := constants.ReplPrompt
+		if len(lines) > 0 {
+			prompt = constants.ReplContinuationPrompt
+		}
+
+		line, err := reader.ReadLine(c.color(yellow, prompt))
+		if errors.Is(err, errInterrupted) {
+			lines = nil
+			continue
+		}
+		if err != nil {
+			// whatever is left is evaluated, so the parser can point out what is missing
+			return strings.Join(lines, "\n"), len(lines) > 0
+		}
+
+		switch strings.TrimSpace(line) {
+		case cancelCommand:
+			lines = nil
+			continue
+		case pasteCommand:
+			if len(lines) == 0 {
+				input, ok := c.readPaste(reader, out)
+				if !ok {
+					continue
+				}
+				return input, true
+			}
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if !isIncomplete(input) {
+			return input, true
+		}
+	}
+}
+
+// readPaste reads lines as they are until the end of paste mode, without looking at brackets.
+// It reports false if the paste was cancelled.
+func (c *config) readPaste(reader lineReader, out io.Writer) (string, bool) {
+	io.WriteString(out, constants.ReplPasteMessage)
+
+	var lines []string
+	for {
+		line, err := reader.ReadLine("")
+		if errors.Is(err, errInterrupted) {
+			return "", false
+		}
+		if err != nil {
+			break
+		}
+
+		switch strings.TrimSpace(line) {
+		case pasteEnd:
+			return strings.Join(lines, "\n"), true
+		case cancelCommand:
+			return "", false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// isIncomplete reports whether a bracket, an embedded string expression, a raw string
+// or a block comment is still open at the end of the input
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+
+	for {
+		tok := l.NextToken()
+
+		switch tok.Type {
+		case token.EOF:
+			return depth > 0
+		case token.LPAREN, token.LBRACKET, token.LBRACE, token.TEMPLATE_START:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE, token.TEMPLATE_END:
+			depth--
+		case token.ILLEGAL:
+			// double quoted strings can not span lines, more input would not fix them
+			rawString := tok.Literal == "unterminated string" && input[tok.Location.Offset] == '`'
+			return rawString || tok.Literal == "unterminated comment"
+		}
+	}
+}
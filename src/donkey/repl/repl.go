@@ -2,53 +2,148 @@ package repl
 
 import (
 	"bufio"
+	"donkey/ast"
 	"donkey/constants"
+	"donkey/diagnostics"
 	"donkey/evaluator"
 	"donkey/lexer"
 	"donkey/object"
 	"donkey/parser"
-	"fmt"
+	"donkey/token"
 	"io"
+	"os"
+	"strings"
 )
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	macroEnv := object.NewEnvironment()
+const (
+	yellow = "\u001b[33m"
+	red    = "\u001b[31m"
+	reset  = "\u001b[0m"
+)
+
+type config struct {
+	colors      bool
+	historyPath string
+}
+
+type Option func(*config)
+
+// WithoutColors disables ANSI colors, e.g. if the output is not a terminal
+func WithoutColors() Option {
+	return func(c *config) {
+		c.colors = false
+	}
+}
+
+// WithHistory keeps the history of entered lines in the file, otherwise it is kept for the session only
+func WithHistory(path string) Option {
+	return func(c *config) {
+		c.historyPath = path
+	}
+}
+
+// color wraps the text in the color code, if colors are enabled
+func (c *config) color(code, text string) string {
+	if !c.colors {
+		return text
+	}
+	return code + text + reset
+}
+
+func Start(in io.Reader, out io.Writer, opts ...Option) {
+	c := &config{colors: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	s := newSession()
+
+	var reader lineReader = &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		complete := func(prefix string) []string {
+			return completions(prefix, token.Keywords(), evaluator.BuiltinNames(), s.env.Names(), s.macroEnv.Names())
+		}
+		reader = &terminalReader{fd: f.Fd(), editor: newLineEditor(f, out, loadHistory(c.historyPath), complete)}
+	}
 
 	for {
-		fmt.Fprintf(out, constants.ReplPrompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, ok := c.readInput(reader, out)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if name, arg, ok := parseCommand(input); ok {
+			c.runCommand(out, s, name, arg)
+		} else {
+			c.evaluate(out, input, s)
+		}
 
-		program := p.ParseProgram()
+		// async functions that failed since the last input and were never awaited
+		evaluator.ReportUnhandledErrors()
+	}
+}
 
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
+// evaluate runs the input and prints its result
+func (c *config) evaluate(out io.Writer, input string, s *session) {
+	if evaled, ok := c.run(out, input, "", s); ok && evaled != nil {
+		io.WriteString(out, evaled.Inspect())
+		io.WriteString(out, "\n")
+	}
+}
 
-		// support macros in REPL
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+// run parses, expands and evaluates the source in the session and binds the result to `_`.
+// Input that is not read from a file is named like one, see entryName. Errors are printed, in which case it reports false.
+func (c *config) run(out io.Writer, source, file string, s *session) (object.Object, bool) {
+	if file == "" {
+		file = s.entryName(source)
+	}
 
-		evaled := evaluator.Eval(expanded, env)
-		if evaled != nil {
-			io.WriteString(out, evaled.Inspect())
-			io.WriteString(out, "\n")
-		}
+	program, ok := c.parse(out, source, file)
+	if !ok {
+		return nil, false
 	}
+
+	// support macros in REPL
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		c.printRuntimeError(out, source, err)
+		return nil, false
+	}
+
+	evaled := evaluator.Eval(expanded, s.env)
+	if err, ok := evaled.(*object.Error); ok {
+		c.printRuntimeError(out, source, err)
+		return nil, false
+	}
+	if evaled != nil {
+		s.env.Set(lastResult, evaled)
+	}
+	return evaled, true
+}
+
+func (c *config) parse(out io.Writer, source, file string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(source, lexer.WithFile(file)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		c.printParserErrors(out, source, p.Errors())
+		return nil, false
+	}
+	return program, true
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+func (c *config) printParserErrors(out io.Writer, source string, errors []*parser.ParseError) {
 	io.WriteString(out, constants.ParserErrorPrompt)
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		c.printError(out, diagnostics.RenderParseError(source, err))
 	}
 }
+
+func (c *config) printRuntimeError(out io.Writer, source string, err *object.Error) {
+	c.printError(out, diagnostics.RenderRuntimeError(source, err))
+}
+
+func (c *config) printError(out io.Writer, rendered string) {
+	rendered = strings.ReplaceAll(rendered, "\n", "\n\t")
+	io.WriteString(out, "\t"+c.color(red, rendered)+"\n")
+}
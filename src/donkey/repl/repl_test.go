@@ -0,0 +1,182 @@
+package repl
+
+import (
+	"bytes"
+	"donkey/constants"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testRepl(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, WithoutColors())
+	return out.String()
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n  a + b\n}", false},
+		{"add(1,", true},
+		{"[1, [2]", true},
+		{"{\"a\": 1", true},
+		{"\"total: ${add(1,", true},
+		{"\"total: ${x}\"", false},
+		{"`raw\nstring", true},
+		{"`raw\nstring`", false},
+		{"/* comment", true},
+		{"\"open string", false},
+		{"fn() { \"open string", false},
+		{"1 + )", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. want=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let add = fn(a, b) {\n" +
+		"  a + b\n" +
+		"};\n" +
+		"add(1,\n" +
+		"2)\n"
+
+	expected := constants.ReplPrompt + constants.ReplContinuationPrompt + constants.ReplContinuationPrompt +
+		constants.ReplPrompt + constants.ReplContinuationPrompt + "3\n" +
+		constants.ReplPrompt
+
+	if out := testRepl(input); out != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", expected, out)
+	}
+}
+
+func TestCancelInput(t *testing.T) {
+	input := "let x = fn() {\n" +
+		":cancel\n" +
+		"1 + 1\n"
+
+	out := testRepl(input)
+	if strings.Contains(out, "parser errors") {
+		t.Errorf("cancelled input was evaluated. got=%q", out)
+	}
+	if !strings.HasSuffix(out, "2\n"+constants.ReplPrompt) {
+		t.Errorf("input after cancel was not evaluated. got=%q", out)
+	}
+}
+
+func TestUnfinishedInputAtEOF(t *testing.T) {
+	out := testRepl("let x = fn() {\n")
+	if !strings.Contains(out, "expected } to close the block") {
+		t.Errorf("unfinished input was not reported. got=%q", out)
+	}
+}
+
+func TestPasteMode(t *testing.T) {
+	input := ":paste\n" +
+		"let a = 1;\n" +
+		"\n" +
+		"let b = 2;\n" +
+		"a + b\n" +
+		":end\n" +
+		":paste\n" +
+		"1 / 0\n" +
+		":cancel\n"
+
+	out := testRepl(input)
+	if !strings.Contains(out, constants.ReplPasteMessage+"3\n") {
+		t.Errorf("pasted input was not evaluated at once. got=%q", out)
+	}
+	if strings.Contains(out, "division by zero") {
+		t.Errorf("cancelled paste was evaluated. got=%q", out)
+	}
+}
+
+func TestLastResult(t *testing.T) {
+	out := testRepl("2 * 3\n_ + 1\nlet x = 10;\n_\n")
+
+	expected := constants.ReplPrompt + "6\n" + constants.ReplPrompt + "7\n" +
+		constants.ReplPrompt + constants.ReplPrompt + "7\n" + constants.ReplPrompt
+	if out != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", expected, out)
+	}
+}
+
+func TestErrorsShowTheirInput(t *testing.T) {
+	out := testRepl("let f = fn() { missing }\nf()\n")
+
+	expected := "\tRuntime error: <repl:1>:1:16 >> identifier not found: missing\n" +
+		"\t1 | let f = fn() { missing }\n" +
+		"\t  |                ^~~~~~~\n" +
+		"\tTraceback (most recent call first):\n" +
+		"\t\tin f, called at <repl:2>:1:1\n"
+	if !strings.Contains(out, expected) {
+		t.Errorf("output does not contain the failing input.\nwant=%q\ngot=%q", expected, out)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+		expectedArg  string
+		expectedOk   bool
+	}{
+		{":env", "env", "", true},
+		{"  :load  lib.dk ", "load", "lib.dk", true},
+		{":ast fn(a) {\n  a\n}", "ast", "fn(a) {\n  a\n}", true},
+		{":ast\nfn() {}", "ast", "fn() {}", true},
+		{"1 + 2", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, arg, ok := parseCommand(tt.input)
+		if name != tt.expectedName || arg != tt.expectedArg || ok != tt.expectedOk {
+			t.Errorf("parseCommand(%q) wrong. want=%q %q %t, got=%q %q %t",
+				tt.input, tt.expectedName, tt.expectedArg, tt.expectedOk, name, arg, ok)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "lib.dk")
+	if err := os.WriteFile(lib, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1;\nlet m = macro(x) { x };\n:env\n", "a = 1\n// macros\nm = macro(x) { ... }\n"},
+		{"let f = fn(x) {\n  x\n};\n:env\n", "f = fn(x) { ... }\n"},
+		{"let a = 1;\n:reset\na\n", "session reset\n" + constants.ReplPrompt + "\tRuntime error"},
+		{":load " + lib + "\ndouble(4)\n", "loaded " + lib + "\n" + constants.ReplPrompt + "8\n"},
+		{":load missing.dk\n", "missing.dk: no such file or directory"},
+		{":tokens a + 1\n", "1:1\tIDENT\t\"a\"\n1:3\t+\t\"+\"\n1:5\tINT\t\"1\"\n1:6\tEOF\t\"\"\n"},
+		{":ast -a\n", "Program 1:1-1:3\n  Statements: ExpressionStatement 1:1-1:3\n" +
+			"    Expression: PrefixExpression 1:1-1:3 Operator=\"-\"\n      Right: Identifier 1:2-1:3 Value=\"a\"\n"},
+		{":ast 1 +\n", "no prefix parse function"},
+		{"let twice = macro(x) { quote(unquote(x) + unquote(x)) };\n:expand twice(f())\n", "(f() + f())\n"},
+		{":expand let m = macro() { quote(1) }; m()\nm()\n", "identifier not found: m"},
+		{":type 1.5\n:type \"a\"\n", "FLOAT\n" + constants.ReplPrompt + "STRING\n"},
+		{":type 1 / 0\n", "division by zero"},
+		{":time 1 + 1\n_\n", "2\ntook "},
+		{":nope\n", "unknown command :nope"},
+	}
+
+	for _, tt := range tests {
+		if out := testRepl(tt.input); !strings.Contains(out, tt.expected) {
+			t.Errorf("%q: output does not contain %q. got=%q", tt.input, tt.expected, out)
+		}
+	}
+}
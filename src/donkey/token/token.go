@@ -1,32 +1,56 @@
 package token
 
+import "sort"
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// Interpolated strings "a ${x} b ${y} c" are lexed as
+	// TEMPLATE_START("a "), x, TEMPLATE_PART(" b "), y, TEMPLATE_END(" c")
+	TEMPLATE_START = "TEMPLATE_START"
+	TEMPLATE_PART  = "TEMPLATE_PART"
+	TEMPLATE_END   = "TEMPLATE_END"
+
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
-	LT       = "<"
-	GT       = ">"
-	EQ       = "=="
-	NOT_EQ   = "!="
-	LT_EQ    = "<="
-	GT_EQ    = ">="
+	ASSIGN          = "="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PLUS            = "+"
+	MINUS           = "-"
+	BANG            = "!"
+	ASTERISK        = "*"
+	SLASH           = "/"
+	LT              = "<"
+	GT              = ">"
+	EQ              = "=="
+	NOT_EQ          = "!="
+	LT_EQ           = "<="
+	GT_EQ           = ">="
+	PERCENT         = "%"
+	POWER           = "**"
+	AND             = "&&"
+	OR              = "||"
+	BIT_AND         = "&"
+	BIT_OR          = "|"
+	BIT_XOR         = "^"
+	SHIFT_LEFT      = "<<"
+	SHIFT_RIGHT     = ">>"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -43,16 +67,60 @@ const (
 	RETURN   = "RETURN"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
-	FOR      = "FOR" // TODO; implement loops
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 	ASYNC    = "ASYNC"
+	AWAIT    = "AWAIT"
 	MACRO    = "MACRO"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	SELECT   = "SELECT"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
 )
 
 type TokenType string
 
+// TokenLocation is the range of source code a token, or an AST node, covers.
+// Lines and columns start at 1 and columns count runes. The end is the position after the last character.
 type TokenLocation struct {
-	Line   int
-	Column int
+	File      string // the source file, empty for input that is not read from a file, e.g. in the REPL
+	Line      int
+	Column    int
+	Offset    int // byte offset of the first character
+	EndLine   int
+	EndColumn int
+	EndOffset int
+}
+
+// Through returns the range from the start of l to the end of last.
+// Locations without a position, e.g. of nodes created by macros, are skipped.
+func (l TokenLocation) Through(last TokenLocation) TokenLocation {
+	if l.Line == 0 {
+		return last
+	}
+	if last.Line == 0 {
+		return l
+	}
+
+	l.EndLine, l.EndColumn, l.EndOffset = last.EndLine, last.EndColumn, last.EndOffset
+	return l
+}
+
+// End returns the empty range right after l
+func (l TokenLocation) End() TokenLocation {
+	return TokenLocation{
+		File:      l.File,
+		Line:      l.EndLine,
+		Column:    l.EndColumn,
+		Offset:    l.EndOffset,
+		EndLine:   l.EndLine,
+		EndColumn: l.EndColumn,
+		EndOffset: l.EndOffset,
+	}
 }
 
 type Token struct {
@@ -62,16 +130,36 @@ type Token struct {
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
-	"for":    FOR,
-	"macro":  MACRO,
-	"async":  ASYNC,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
+	"async":    ASYNC,
+	"await":    AWAIT,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
+	"select":   SELECT,
+	"case":     CASE,
+	"default":  DEFAULT,
+}
+
+// Keywords returns all keywords in alphabetical order, e.g. for completion
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {